@@ -0,0 +1,77 @@
+package tftidy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultBackupSuffix is appended to backups when --backup is given without a
+// suffix.
+const defaultBackupSuffix = ".bak"
+
+// backupOptions selects where the original content of modified files is
+// kept: next to the file with suffix appended, or under dir mirroring the
+// file's path relative to the current directory, or both.
+type backupOptions struct {
+	suffix string
+	dir    string
+}
+
+func (opts backupOptions) enabled() bool {
+	return opts.suffix != "" || opts.dir != ""
+}
+
+// backupPath returns where the backup of path is written.
+func (opts backupOptions) backupPath(path string) (string, error) {
+	if opts.dir == "" {
+		return path + opts.suffix, nil
+	}
+
+	rel, below, err := relativeToWorkingDir(path)
+	if err != nil {
+		return "", err
+	}
+	if !below {
+		// Outside the current directory, mirror the absolute path instead.
+		rel = strings.TrimPrefix(rel, filepath.VolumeName(rel))
+	}
+
+	return filepath.Join(opts.dir, rel) + opts.suffix, nil
+}
+
+// relativeToWorkingDir returns path relative to the current directory and
+// true when it is below it, and the absolute path and false otherwise.
+func relativeToWorkingDir(path string) (string, bool, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", false, err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", false, err
+	}
+
+	rel, err := filepath.Rel(cwd, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return absPath, false, nil
+	}
+	return rel, true, nil
+}
+
+// writeBackup stores content, the original content of path, at its backup
+// path with the same permissions as path.
+func writeBackup(path string, content []byte, opts backupOptions) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	backup, err := opts.backupPath(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(backup), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(backup, content, info.Mode().Perm())
+}
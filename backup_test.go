@@ -0,0 +1,148 @@
+package tftidy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupPath(t *testing.T) {
+	t.Parallel()
+
+	outside := filepath.Join(t.TempDir(), "live", "main.tf")
+
+	tests := []struct {
+		name     string
+		opts     backupOptions
+		path     string
+		expected string
+	}{
+		{
+			name:     "suffix",
+			opts:     backupOptions{suffix: ".bak"},
+			path:     filepath.Join("live", "main.tf"),
+			expected: filepath.Join("live", "main.tf.bak"),
+		},
+		{
+			name:     "dir mirrors relative path",
+			opts:     backupOptions{dir: "backup"},
+			path:     filepath.Join("live", "main.tf"),
+			expected: filepath.Join("backup", "live", "main.tf"),
+		},
+		{
+			name:     "dir and suffix",
+			opts:     backupOptions{dir: "backup", suffix: "~"},
+			path:     filepath.Join("live", "main.tf"),
+			expected: filepath.Join("backup", "live", "main.tf~"),
+		},
+		{
+			name:     "dir mirrors absolute path outside the current directory",
+			opts:     backupOptions{dir: "backup"},
+			path:     outside,
+			expected: filepath.Join("backup", strings.TrimPrefix(outside, filepath.VolumeName(outside))),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.opts.backupPath(tt.path)
+			if err != nil {
+				t.Fatalf("backupPath failed: %v", err)
+			}
+			if got != tt.expected {
+				t.Fatalf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestIntegrationRunBackup(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	backupDir := t.TempDir()
+	moved := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+	file := filepath.Join(tempDir, "live", "main.tf")
+	untouched := filepath.Join(tempDir, "live", "outputs.tf")
+	mustMkdirAll(t, filepath.Dir(file))
+	mustWriteFile(t, file, moved, 0o600)
+	mustWriteFile(t, untouched, "output \"id\" {\n  value = 1\n}\n", 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--backup", "--backup-dir", backupDir, tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+
+	backup := filepath.Join(backupDir, strings.TrimPrefix(file, filepath.VolumeName(file))) + defaultBackupSuffix
+	content, err := os.ReadFile(backup)
+	if err != nil {
+		t.Fatalf("backup not written: %v", err)
+	}
+	if string(content) != moved {
+		t.Fatalf("unexpected backup content: %q", string(content))
+	}
+	info, err := os.Stat(backup)
+	if err != nil {
+		t.Fatalf("failed to stat backup: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("backup permissions not preserved: %v", info.Mode())
+	}
+
+	if _, err := os.Stat(filepath.Join(backupDir, strings.TrimPrefix(untouched, filepath.VolumeName(untouched))) + defaultBackupSuffix); !os.IsNotExist(err) {
+		t.Fatalf("unmodified files must not be backed up: %v", err)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	mustWriteFile(t, file, moved, 0o600)
+	code = run([]string{"--dry-run", "--backup=.orig", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if _, err := os.Stat(file + ".orig"); !os.IsNotExist(err) {
+		t.Fatalf("dry-run must not write backups: %v", err)
+	}
+}
+
+func TestIntegrationRunBackupDirInsideScanRoot(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	backupDir := filepath.Join(tempDir, "backups")
+	moved := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+	file := filepath.Join(tempDir, "main.tf")
+	mustWriteFile(t, file, moved, 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--backup-dir", backupDir, tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	backup := filepath.Join(backupDir, strings.TrimPrefix(file, filepath.VolumeName(file)))
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"--verbose", "--backup-dir", backupDir, tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Skipping: "+backupDir+" (backup directory)") {
+		t.Fatalf("backup directory should be skipped:\n%s", stdout.String())
+	}
+
+	content, err := os.ReadFile(backup)
+	if err != nil {
+		t.Fatalf("backup not written: %v", err)
+	}
+	if string(content) != moved {
+		t.Fatalf("backup must not be cleaned by a later run: %q", string(content))
+	}
+}
@@ -0,0 +1,239 @@
+package tftidy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/spf13/pflag"
+)
+
+// blockDuplicate is a transient block that repeats an earlier block of the
+// same module verbatim.
+type blockDuplicate struct {
+	original  *transientBlock
+	duplicate *transientBlock
+}
+
+// blockConflict is a pair of transient blocks of the same type that target
+// the same address with different content.
+type blockConflict struct {
+	first *transientBlock
+	other *transientBlock
+}
+
+func runCheck(args []string, stdout, stderr io.Writer) int {
+	fs := pflag.NewFlagSet("tftidy check", pflag.ContinueOnError)
+	fs.SortFlags = false
+	fs.SetOutput(stderr)
+
+	deleteDuplicates := fs.Bool("delete-duplicates", false, "Delete exact duplicate blocks, keeping the first occurrence")
+	dryRun := fs.BoolP("dry-run", "n", false, "Preview changes without modifying files")
+	verbose := fs.BoolP("verbose", "v", false, "Show each file being checked")
+	discovery := addDiscoveryFlags(fs)
+	showHelp := fs.BoolP("help", "h", false, "Show help")
+
+	if err := fs.Parse(args); err != nil {
+		writef(stderr, "Error: %v\n\n", err)
+		printCheckUsage(stderr)
+		return 2
+	}
+
+	if *showHelp {
+		printCheckUsage(stdout)
+		return 0
+	}
+
+	remaining := fs.Args()
+	if len(remaining) > 1 {
+		writef(stderr, "Error: expected at most one directory argument\n\n")
+		printCheckUsage(stderr)
+		return 2
+	}
+
+	dir := "."
+	if len(remaining) == 1 {
+		dir = remaining[0]
+	}
+
+	discover, err := discovery.options()
+	if err != nil {
+		writef(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if *verbose {
+		discover.skipped = func(path, reason string) {
+			writef(stdout, "Skipping: %s (%s)\n", path, reason)
+		}
+	}
+	files, err := walkFiles(dir, discover, isTerraformFile)
+	if err != nil {
+		writef(stderr, "Error: failed to discover Terraform files: %v\n", err)
+		return 1
+	}
+	files = nativeSyntaxFiles(files)
+
+	errored := 0
+	duplicatesFound := 0
+	duplicatesDeleted := 0
+	conflictsFound := 0
+	versionWarnings := 0
+	for _, moduleFiles := range groupFilesByDir(files) {
+		contents := make(map[string][]byte, len(moduleFiles))
+		blocks := make([]*transientBlock, 0)
+		constraints := make([]string, 0)
+		requirements := make([]versionRequirement, 0)
+		for _, path := range moduleFiles {
+			if *verbose {
+				writef(stdout, "Checking: %s\n", path)
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				errored++
+				writef(stderr, "Error processing %s: %v\n", path, err)
+				continue
+			}
+			found, err := collectTransientBlocks(content, path, allowedBlockTypes)
+			if err != nil {
+				errored++
+				writef(stderr, "Error processing %s: %v\n", path, err)
+				continue
+			}
+			contents[path] = content
+			blocks = append(blocks, found...)
+
+			if body, err := parseModuleBody(content, path); err == nil {
+				constraints = append(constraints, requiredVersions(body)...)
+				requirements = append(requirements, transientBlockRequirements(body, content, path)...)
+			}
+		}
+
+		if len(constraints) > 0 {
+			warnings, err := lintVersionRequirements(stdout, constraints, requirements)
+			if err != nil {
+				errored++
+				writef(stderr, "Error in module %s: %v\n", filepath.Dir(moduleFiles[0]), err)
+			}
+			versionWarnings += warnings
+		}
+
+		duplicates, conflicts := findDuplicateBlocks(blocks)
+		for _, d := range duplicates {
+			writef(stdout, "Duplicate %s block at %s (first declared at %s)\n", d.duplicate.blockType, d.duplicate.position(), d.original.position())
+		}
+		for _, c := range conflicts {
+			writef(stdout, "Conflicting %s blocks for %s at %s and %s\n", c.first.blockType, c.first.address, c.first.position(), c.other.position())
+		}
+		duplicatesFound += len(duplicates)
+		conflictsFound += len(conflicts)
+
+		if !*deleteDuplicates || len(duplicates) == 0 {
+			continue
+		}
+
+		rangesByPath := make(map[string][]byteRange)
+		for _, d := range duplicates {
+			b := d.duplicate
+			rangesByPath[b.path] = append(rangesByPath[b.path], byteRange{start: b.rng.Start.Byte, end: b.rng.End.Byte})
+		}
+
+		for _, path := range moduleFiles {
+			ranges, ok := rangesByPath[path]
+			if !ok {
+				continue
+			}
+			if *dryRun {
+				duplicatesDeleted += len(ranges)
+				continue
+			}
+			updated := hclwrite.Format(removeByteRanges(contents[path], ranges))
+			if err := writeFileAtomic(path, updated, false); err != nil {
+				errored++
+				writef(stderr, "Error processing %s: %v\n", path, err)
+				continue
+			}
+			duplicatesDeleted += len(ranges)
+		}
+	}
+
+	writeln(stdout)
+	writef(stdout, "Duplicates found: %d\n", duplicatesFound)
+	if *deleteDuplicates {
+		writef(stdout, "Duplicates deleted: %d\n", duplicatesDeleted)
+	}
+	writef(stdout, "Conflicts found: %d\n", conflictsFound)
+	writef(stdout, "Version warnings: %d\n", versionWarnings)
+
+	if errored > 0 || conflictsFound > 0 || duplicatesFound > duplicatesDeleted {
+		return 1
+	}
+
+	return 0
+}
+
+// findDuplicateBlocks compares the transient blocks of one module. Blocks of
+// the same type with identical formatted content are duplicates of the first
+// occurrence; blocks that share a type and address but differ in content are
+// conflicts, which Terraform rejects.
+func findDuplicateBlocks(blocks []*transientBlock) ([]blockDuplicate, []blockConflict) {
+	byContent := make(map[string]*transientBlock, len(blocks))
+	byAddress := make(map[string]*transientBlock, len(blocks))
+	duplicates := make([]blockDuplicate, 0)
+	conflicts := make([]blockConflict, 0)
+
+	for _, b := range blocks {
+		contentKey := b.blockType + "\x00" + string(hclwrite.Format(b.source))
+		if original, ok := byContent[contentKey]; ok {
+			duplicates = append(duplicates, blockDuplicate{original: original, duplicate: b})
+			continue
+		}
+		byContent[contentKey] = b
+
+		if b.address == "" {
+			continue
+		}
+		addressKey := b.blockType + "\x00" + b.address
+		if first, ok := byAddress[addressKey]; ok {
+			conflicts = append(conflicts, blockConflict{first: first, other: b})
+			continue
+		}
+		byAddress[addressKey] = b
+	}
+
+	return duplicates, conflicts
+}
+
+// lintVersionRequirements warns about every requirement that the module's
+// required_version constraints allow an older Terraform to violate.
+func lintVersionRequirements(stdout io.Writer, constraints []string, requirements []versionRequirement) (int, error) {
+	warnings := 0
+	for _, req := range requirements {
+		allowsOlder, err := allowsVersionsBelow(constraints, req.minimum)
+		if err != nil {
+			return warnings, fmt.Errorf("invalid required_version: %w", err)
+		}
+		if !allowsOlder {
+			continue
+		}
+		warnings++
+		writef(stdout, "Warning: %s:%d: %s require Terraform >= %s, but required_version %q allows older versions\n",
+			req.path, req.line, req.feature, req.minimum, strings.Join(constraints, ", "))
+	}
+	return warnings, nil
+}
+
+func printCheckUsage(w io.Writer) {
+	writeln(w, "tftidy check - Report duplicate, conflicting, and version-incompatible transient blocks")
+	writeln(w)
+	writeln(w, "Usage: tftidy check [options] [directory]")
+	writeln(w)
+	writeln(w, "Options:")
+	writeln(w, "      --delete-duplicates        Delete exact duplicate blocks, keeping the first occurrence")
+	writeln(w, "  -n, --dry-run                  Preview changes without modifying files")
+	writeln(w, "  -v, --verbose                  Show each file being checked")
+	printDiscoveryUsage(w)
+	writeln(w, "  -h, --help                     Show help")
+}
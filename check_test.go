@@ -0,0 +1,128 @@
+package tftidy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindDuplicateBlocks(t *testing.T) {
+	t.Parallel()
+
+	first := `import {
+  to = aws_instance.main
+  id = "i-123"
+}
+`
+	second := `import {
+  to   = aws_instance.main
+  id   = "i-123"
+}
+
+import {
+  to = aws_instance.main
+  id = "i-456"
+}
+
+moved {
+  from = aws_instance.old
+  to   = aws_instance.main
+}
+`
+
+	blocksA, err := collectTransientBlocks([]byte(first), "a.tf", allowedBlockTypes)
+	if err != nil {
+		t.Fatalf("collectTransientBlocks failed: %v", err)
+	}
+	blocksB, err := collectTransientBlocks([]byte(second), "b.tf", allowedBlockTypes)
+	if err != nil {
+		t.Fatalf("collectTransientBlocks failed: %v", err)
+	}
+
+	duplicates, conflicts := findDuplicateBlocks(append(blocksA, blocksB...))
+	if len(duplicates) != 1 {
+		t.Fatalf("expected one duplicate, got %d", len(duplicates))
+	}
+	if got := duplicates[0].duplicate.position(); got != "b.tf:1" {
+		t.Fatalf("unexpected duplicate position: %s", got)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("expected one conflict, got %d", len(conflicts))
+	}
+	if conflicts[0].first.address != "aws_instance.main" || conflicts[0].other.position() != "b.tf:6" {
+		t.Fatalf("unexpected conflict: %s at %s", conflicts[0].first.address, conflicts[0].other.position())
+	}
+}
+
+func TestRunCheckDeleteDuplicates(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	block := `moved {
+  from = aws_instance.old
+  to   = aws_instance.main
+}
+`
+	first := filepath.Join(tempDir, "a.tf")
+	second := filepath.Join(tempDir, "b.tf")
+	mustWriteFile(t, first, block, 0o644)
+	mustWriteFile(t, second, "resource \"aws_instance\" \"main\" {}\n\n"+block, 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"check", tempDir}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("expected exit code 1 for unresolved duplicate, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "Duplicate moved block at "+second+":3") {
+		t.Fatalf("unexpected stdout: %s", stdout.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"check", "--delete-duplicates", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Duplicates deleted: 1") {
+		t.Fatalf("unexpected stdout: %s", stdout.String())
+	}
+
+	content, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != block {
+		t.Fatalf("first occurrence should be kept:\n%s", string(content))
+	}
+
+	content, err = os.ReadFile(second)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if containsBlockDeclaration(string(content), "moved") {
+		t.Fatalf("duplicate should be deleted:\n%s", string(content))
+	}
+}
+
+func TestRunCheckConflictAcrossModulesIsIgnored(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	mustMkdirAll(t, filepath.Join(tempDir, "a"))
+	mustMkdirAll(t, filepath.Join(tempDir, "b"))
+	mustWriteFile(t, filepath.Join(tempDir, "a", "main.tf"), "import {\n  to = aws_instance.main\n  id = \"i-1\"\n}\n", 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "b", "main.tf"), "import {\n  to = aws_instance.main\n  id = \"i-2\"\n}\n", 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"check", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stdout=%s stderr=%s", code, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Conflicts found: 0") {
+		t.Fatalf("unexpected stdout: %s", stdout.String())
+	}
+}
@@ -0,0 +1,206 @@
+package tftidy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// terraformVersion is a major.minor.patch version. Pre-release suffixes are
+// ignored because only the lower bound of a constraint matters here.
+type terraformVersion [3]int
+
+func (v terraformVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+func (v terraformVersion) less(other terraformVersion) bool {
+	for i := range v {
+		if v[i] != other[i] {
+			return v[i] < other[i]
+		}
+	}
+	return false
+}
+
+// versionRequirement is a transient block feature together with the first
+// Terraform version able to parse it.
+type versionRequirement struct {
+	path    string
+	line    int
+	feature string
+	minimum terraformVersion
+}
+
+var (
+	movedMinimumVersion          = terraformVersion{1, 1, 0}
+	importMinimumVersion         = terraformVersion{1, 5, 0}
+	importForEachMinimumVersion  = terraformVersion{1, 7, 0}
+	removedMinimumVersion        = terraformVersion{1, 7, 0}
+	crossTypeMovedMinimumVersion = terraformVersion{1, 8, 0}
+)
+
+func parseTerraformVersion(raw string) (terraformVersion, error) {
+	var v terraformVersion
+
+	raw = strings.TrimPrefix(strings.TrimSpace(raw), "v")
+	if i := strings.IndexAny(raw, "-+"); i >= 0 {
+		raw = raw[:i]
+	}
+
+	parts := strings.Split(raw, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid version %q", raw)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", raw)
+		}
+		v[i] = n
+	}
+
+	return v, nil
+}
+
+// allowsVersionsBelow reports whether the combined version constraints admit
+// any Terraform version older than minimum. Only lower bounds are considered:
+// upper bounds and exclusions can narrow the allowed set but never raise its
+// floor. An empty constraint list allows every version.
+func allowsVersionsBelow(constraints []string, minimum terraformVersion) (bool, error) {
+	bounded := false
+	var lower terraformVersion
+	lowerExclusive := false
+
+	for _, constraint := range constraints {
+		for _, part := range strings.Split(constraint, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+
+			op := ""
+			for _, candidate := range []string{">=", "<=", "!=", "~>", ">", "<", "="} {
+				if strings.HasPrefix(part, candidate) {
+					op = candidate
+					break
+				}
+			}
+
+			v, err := parseTerraformVersion(strings.TrimSpace(strings.TrimPrefix(part, op)))
+			if err != nil {
+				return false, err
+			}
+
+			switch op {
+			case "", "=", ">=", "~>":
+				if !bounded || lower.less(v) || (lower == v && lowerExclusive) {
+					lower, lowerExclusive = v, false
+				}
+				bounded = true
+			case ">":
+				if !bounded || lower.less(v) {
+					lower, lowerExclusive = v, true
+				}
+				bounded = true
+			}
+		}
+	}
+
+	if !bounded {
+		return true, nil
+	}
+	return lower.less(minimum), nil
+}
+
+// requiredVersions returns the required_version constraints declared in the
+// terraform blocks of body.
+func requiredVersions(body *hclsyntax.Body) []string {
+	constraints := make([]string, 0)
+	for _, block := range body.Blocks {
+		if block.Type != "terraform" {
+			continue
+		}
+		attr, ok := block.Body.Attributes["required_version"]
+		if !ok {
+			continue
+		}
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || value.IsNull() || !value.Type().Equals(cty.String) {
+			continue
+		}
+		constraints = append(constraints, value.AsString())
+	}
+	return constraints
+}
+
+// transientBlockRequirements lists the version requirements of the top-level
+// transient blocks in body.
+func transientBlockRequirements(body *hclsyntax.Body, content []byte, path string) []versionRequirement {
+	requirements := make([]versionRequirement, 0)
+	for _, block := range body.Blocks {
+		line := block.Range().Start.Line
+		switch block.Type {
+		case "moved":
+			requirements = append(requirements, versionRequirement{path: path, line: line, feature: "moved blocks", minimum: movedMinimumVersion})
+
+			from, hasFrom := block.Body.Attributes["from"]
+			to, hasTo := block.Body.Attributes["to"]
+			if !hasFrom || !hasTo {
+				continue
+			}
+			fromType := resourceTypeOf(string(from.Expr.Range().SliceBytes(content)))
+			toType := resourceTypeOf(string(to.Expr.Range().SliceBytes(content)))
+			if fromType != "" && toType != "" && fromType != toType {
+				requirements = append(requirements, versionRequirement{path: path, line: line, feature: "moved blocks across resource types", minimum: crossTypeMovedMinimumVersion})
+			}
+		case "import":
+			requirements = append(requirements, versionRequirement{path: path, line: line, feature: "import blocks", minimum: importMinimumVersion})
+			if _, ok := block.Body.Attributes["for_each"]; ok {
+				requirements = append(requirements, versionRequirement{path: path, line: line, feature: "import blocks with for_each", minimum: importForEachMinimumVersion})
+			}
+		case "removed":
+			requirements = append(requirements, versionRequirement{path: path, line: line, feature: "removed blocks", minimum: removedMinimumVersion})
+		}
+	}
+	return requirements
+}
+
+// resourceTypeOf returns the resource type of a resource address such as
+// module.app.aws_instance.web[0], or "" for module addresses.
+func resourceTypeOf(address string) string {
+	segments := make([]string, 0)
+	depth := 0
+	var current strings.Builder
+	for _, r := range address {
+		switch {
+		case r == '[':
+			depth++
+		case r == ']':
+			depth--
+		case depth > 0:
+		case r == '.':
+			segments = append(segments, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	segments = append(segments, current.String())
+
+	for len(segments) >= 2 && segments[0] == "module" {
+		segments = segments[2:]
+	}
+
+	switch {
+	case len(segments) == 3 && segments[0] == "data":
+		return "data." + segments[1]
+	case len(segments) == 2:
+		return segments[0]
+	default:
+		return ""
+	}
+}
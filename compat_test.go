@@ -0,0 +1,115 @@
+package tftidy
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAllowsVersionsBelow(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		constraints []string
+		minimum     terraformVersion
+		want        bool
+		wantErr     bool
+	}{
+		{name: "unconstrained", constraints: nil, minimum: importMinimumVersion, want: true},
+		{name: "older floor", constraints: []string{">= 1.3.0"}, minimum: importMinimumVersion, want: true},
+		{name: "equal floor", constraints: []string{">= 1.5"}, minimum: importMinimumVersion, want: false},
+		{name: "pessimistic", constraints: []string{"~> 1.4.0"}, minimum: importMinimumVersion, want: true},
+		{name: "exact", constraints: []string{"1.7.2"}, minimum: removedMinimumVersion, want: false},
+		{name: "exclusive floor", constraints: []string{"> 1.6.9"}, minimum: removedMinimumVersion, want: true},
+		{name: "upper bound only", constraints: []string{"< 2.0.0"}, minimum: movedMinimumVersion, want: true},
+		{name: "combined across files", constraints: []string{">= 1.0", ">= 1.8, < 2.0"}, minimum: crossTypeMovedMinimumVersion, want: false},
+		{name: "invalid", constraints: []string{">= one"}, minimum: movedMinimumVersion, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := allowsVersionsBelow(tc.constraints, tc.minimum)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestResourceTypeOf(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"aws_instance.web":                   "aws_instance",
+		"aws_instance.web[0]":                "aws_instance",
+		`module.app["a.b"].aws_instance.web`: "aws_instance",
+		"data.aws_ami.latest":                "data.aws_ami",
+		"module.app":                         "",
+		"module.app.module.db":               "",
+	}
+
+	for address, want := range tests {
+		if got := resourceTypeOf(address); got != want {
+			t.Fatalf("resourceTypeOf(%q): expected %q, got %q", address, want, got)
+		}
+	}
+}
+
+func TestRunCheckVersionWarnings(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	mustWriteFile(t, filepath.Join(tempDir, "versions.tf"), `terraform {
+  required_version = ">= 1.5.0"
+}
+`, 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "main.tf"), `import {
+  to = aws_instance.main
+  id = "i-123"
+}
+
+removed {
+  from = aws_instance.legacy
+}
+
+moved {
+  from = aws_instance.old
+  to   = aws_spot_instance_request.main
+}
+`, 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"check", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("warnings must not fail the check, got %d stderr=%s", code, stderr.String())
+	}
+
+	out := stdout.String()
+	if strings.Contains(out, "import blocks require") {
+		t.Fatalf("import blocks are supported by the constraint:\n%s", out)
+	}
+	if !strings.Contains(out, filepath.Join(tempDir, "main.tf")+":6: removed blocks require Terraform >= 1.7.0") {
+		t.Fatalf("missing removed warning:\n%s", out)
+	}
+	if !strings.Contains(out, "moved blocks across resource types require Terraform >= 1.8.0") {
+		t.Fatalf("missing cross-type moved warning:\n%s", out)
+	}
+	if !strings.Contains(out, "Version warnings: 2") {
+		t.Fatalf("unexpected warning count:\n%s", out)
+	}
+}
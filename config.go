@@ -0,0 +1,56 @@
+package tftidy
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// defaultConfigFile is read from the current directory when --config is not
+// given. A missing default file is not an error.
+const defaultConfigFile = ".tftidy.hcl"
+
+// config holds the settings that can be kept in a config file. Command-line
+// values are added to the ones read from the file.
+type config struct {
+	Include []string `hcl:"include,optional"`
+	Exclude []string `hcl:"exclude,optional"`
+}
+
+// loadConfig reads the config file at path, or the default config file when
+// path is empty.
+func loadConfig(path string) (config, error) {
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigFile
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return config{}, nil
+		}
+		return config{}, err
+	}
+
+	return parseConfig(content, path)
+}
+
+func parseConfig(content []byte, filename string) (config, error) {
+	file, diags := hclsyntax.ParseConfig(content, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return config{}, fmt.Errorf("failed to parse %s: %s", filename, diags.Error())
+	}
+
+	var cfg config
+	if diags := gohcl.DecodeBody(file.Body, nil, &cfg); diags.HasErrors() {
+		return config{}, fmt.Errorf("invalid config %s: %s", filename, diags.Error())
+	}
+
+	return cfg, nil
+}
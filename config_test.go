@@ -0,0 +1,167 @@
+package tftidy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := parseConfig([]byte("include = [\"modules/**\"]\nexclude = [\"**/examples\", \"vendor\"]\n"), ".tftidy.hcl")
+	if err != nil {
+		t.Fatalf("parseConfig failed: %v", err)
+	}
+	expected := config{Include: []string{"modules/**"}, Exclude: []string{"**/examples", "vendor"}}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Fatalf("unexpected config: %#v", cfg)
+	}
+
+	if _, err := parseConfig([]byte("unknown = true\n"), ".tftidy.hcl"); err == nil {
+		t.Fatal("expected error for unknown attribute")
+	}
+}
+
+func TestLoadConfigMissing(t *testing.T) {
+	t.Parallel()
+
+	if _, err := loadConfig(filepath.Join(t.TempDir(), "missing.hcl")); err == nil {
+		t.Fatal("expected error for missing explicit config file")
+	}
+}
+
+func TestIntegrationRunConfigAndExclude(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	configFile := filepath.Join(tempDir, "tftidy.hcl")
+	mustWriteFile(t, configFile, "exclude = [\"vendor\"]\n", 0o644)
+
+	input := "moved {\n  from = a.b\n  to   = a.c\n}\n"
+	for _, dir := range []string{"app", "vendor", "legacy"} {
+		mustMkdirAll(t, filepath.Join(tempDir, dir))
+		mustWriteFile(t, filepath.Join(tempDir, dir, "main.tf"), input, 0o644)
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--config", configFile, "--exclude", "legacy/**", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Files processed: 1") {
+		t.Fatalf("only app/main.tf should be processed:\n%s", stdout.String())
+	}
+
+	for dir, want := range map[string]string{"app": "", "vendor": input, "legacy": input} {
+		content, err := os.ReadFile(filepath.Join(tempDir, dir, "main.tf"))
+		if err != nil {
+			t.Fatalf("failed to read file: %v", err)
+		}
+		if string(content) != want {
+			t.Fatalf("unexpected content in %s: %q", dir, string(content))
+		}
+	}
+}
+
+func TestIntegrationRunExcludeGlobs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		exclude       string
+		wantCode      int
+		wantProcessed string
+		wantStderr    string
+	}{
+		{name: "alternation", exclude: "{examples,fixtures}", wantProcessed: "Files processed: 1"},
+		{name: "invalid", exclude: "{examples,fixtures", wantCode: 1, wantStderr: `invalid exclude glob "{examples,fixtures"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			input := "moved {\n  from = a.b\n  to   = a.c\n}\n"
+			for _, dir := range []string{"app", "examples", "fixtures"} {
+				mustMkdirAll(t, filepath.Join(tempDir, dir))
+				mustWriteFile(t, filepath.Join(tempDir, dir, "main.tf"), input, 0o644)
+			}
+
+			var stdout bytes.Buffer
+			var stderr bytes.Buffer
+			code := run([]string{"--exclude", tt.exclude, tempDir}, &stdout, &stderr)
+			if code != tt.wantCode {
+				t.Fatalf("expected exit code %d, got %d stderr=%s", tt.wantCode, code, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.wantProcessed) {
+				t.Fatalf("unexpected stats:\n%s", stdout.String())
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Fatalf("unexpected stderr: %s", stderr.String())
+			}
+		})
+	}
+}
+
+func TestIntegrationSubcommandsHonorConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		args  []string
+		input string
+	}{
+		{
+			name:  "check",
+			args:  []string{"check", "--delete-duplicates"},
+			input: "moved {\n  from = a.b\n  to   = a.c\n}\n\nmoved {\n  from = a.b\n  to   = a.c\n}\n",
+		},
+		{
+			name:  "collapse-moves",
+			args:  []string{"collapse-moves"},
+			input: "moved {\n  from = a.b\n  to   = a.c\n}\n\nmoved {\n  from = a.c\n  to   = a.d\n}\n",
+		},
+		{
+			name:  "sort",
+			args:  []string{"sort"},
+			input: "moved {\n  from = a.c\n  to   = a.d\n}\n\nmoved {\n  from = a.b\n  to   = a.c\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			configFile := filepath.Join(tempDir, "tftidy.hcl")
+			mustWriteFile(t, configFile, "exclude = [\"examples\"]\n", 0o644)
+			for _, dir := range []string{"app", "examples", "legacy"} {
+				mustMkdirAll(t, filepath.Join(tempDir, dir))
+				mustWriteFile(t, filepath.Join(tempDir, dir, "main.tf"), tt.input, 0o644)
+			}
+
+			var stdout bytes.Buffer
+			var stderr bytes.Buffer
+			args := append(tt.args, "--config", configFile, "--exclude", "legacy", tempDir)
+			if code := run(args, &stdout, &stderr); code != 0 {
+				t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+			}
+
+			for dir, changed := range map[string]bool{"app": true, "examples": false, "legacy": false} {
+				content, err := os.ReadFile(filepath.Join(tempDir, dir, "main.tf"))
+				if err != nil {
+					t.Fatalf("failed to read file: %v", err)
+				}
+				if got := string(content) != tt.input; got != changed {
+					t.Fatalf("%s/main.tf changed = %v, want %v:\n%s", dir, got, changed, string(content))
+				}
+			}
+		})
+	}
+}
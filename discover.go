@@ -1,31 +1,264 @@
 package tftidy
 
 import (
-	"github.com/boyter/gocodewalker"
+	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
 )
 
-func discoverFiles(dir string) ([]string, error) {
-	fileCh := make(chan *gocodewalker.File, 256)
-	walker := gocodewalker.NewFileWalker(dir, fileCh)
-	walker.AllowListExtensions = append(walker.AllowListExtensions, "tf")
-	walker.ExcludeDirectory = append(walker.ExcludeDirectory, ".terraform", ".terragrunt-cache")
+// discoverOptions selects the optional kinds of files a directory walk
+// returns in addition to Terraform files, the doublestar globs that narrow it,
+// and which ignore files and hidden entries it honors. Globs match paths
+// relative to the directory being walked. backupDir, when set, is pruned so
+// backups written by an earlier run are not cleaned. skipped, when set, is
+// called with every pruned directory and every skipped candidate file.
+type discoverOptions struct {
+	terragrunt     bool
+	markdown       bool
+	include        []string
+	exclude        []string
+	noIgnore       bool
+	noGitignore    bool
+	hidden         bool
+	followSymlinks bool
+	backupDir      string
+	skipped        func(path, reason string)
+}
+
+// discoveryFlags are the flags that shape a directory walk, shared by the
+// default command and every subcommand that walks a directory.
+type discoveryFlags struct {
+	include        *[]string
+	exclude        *[]string
+	noIgnore       *bool
+	noGitignore    *bool
+	hidden         *bool
+	followSymlinks *bool
+	configPath     *string
+}
 
-	errCh := make(chan error, 1)
-	go func() {
-		errCh <- walker.Start()
-	}()
+func addDiscoveryFlags(fs *pflag.FlagSet) *discoveryFlags {
+	return &discoveryFlags{
+		include:        fs.StringArray("include", nil, "Only discover files matching this glob, relative to the scanned directory (repeatable)"),
+		exclude:        fs.StringArray("exclude", nil, "Skip files and directories matching this glob, relative to the scanned directory (repeatable)"),
+		noIgnore:       fs.Bool("no-ignore", false, "Do not honor .gitignore, .ignore, .tftidyignore, or .gitmodules files"),
+		noGitignore:    fs.Bool("no-gitignore", false, "Do not honor .gitignore or .gitmodules files"),
+		hidden:         fs.Bool("hidden", false, "Also discover hidden files and directories"),
+		followSymlinks: fs.Bool("follow-symlinks", false, "Follow symlinked files and directories during discovery"),
+		configPath:     fs.String("config", "", "Read settings from this file (default \".tftidy.hcl\" if present)"),
+	}
+}
 
-	files := make([]string, 0, 256)
-	for file := range fileCh {
-		files = append(files, filepath.Clean(file.Location))
+// options loads the config file and combines its globs with the parsed
+// flags.
+func (f *discoveryFlags) options() (discoverOptions, error) {
+	cfg, err := loadConfig(*f.configPath)
+	if err != nil {
+		return discoverOptions{}, fmt.Errorf("failed to load config: %w", err)
 	}
 
-	if err := <-errCh; err != nil {
-		return nil, err
+	opts := discoverOptions{
+		include:        append(cfg.Include, *f.include...),
+		exclude:        append(cfg.Exclude, *f.exclude...),
+		noIgnore:       *f.noIgnore,
+		noGitignore:    *f.noGitignore,
+		hidden:         *f.hidden,
+		followSymlinks: *f.followSymlinks,
+	}
+	if err := validateGlobs("include", opts.include); err != nil {
+		return discoverOptions{}, err
+	}
+	if err := validateGlobs("exclude", opts.exclude); err != nil {
+		return discoverOptions{}, err
+	}
+	return opts, nil
+}
+
+func printDiscoveryUsage(w io.Writer) {
+	writeln(w, "      --include glob             Only discover files matching this glob, relative to the scanned directory (repeatable)")
+	writeln(w, "      --exclude glob             Skip files and directories matching this glob, relative to the scanned directory (repeatable)")
+	writeln(w, "      --no-ignore                Do not honor .gitignore, .ignore, .tftidyignore, or .gitmodules files")
+	writeln(w, "      --no-gitignore             Do not honor .gitignore or .gitmodules files")
+	writeln(w, "      --hidden                   Also discover hidden files and directories")
+	writeln(w, "      --follow-symlinks          Follow symlinked files and directories during discovery")
+	writeln(w, "      --config file              Read settings from this file (default \".tftidy.hcl\" if present)")
+}
+
+// resolveInputs expands the command-line paths and listed files into the
+// sorted, deduplicated list of files to process. Directories are walked;
+// files are taken as given, even when ignore rules would have skipped them,
+// as long as opts supports their type. Listed files are neither walked nor
+// checked for existence, so a missing one surfaces as a processing error.
+func resolveInputs(paths []string, listed []string, opts discoverOptions) ([]string, error) {
+	seen := make(map[string]struct{})
+	files := make([]string, 0)
+	add := func(path string) {
+		if _, ok := seen[path]; ok {
+			return
+		}
+		seen[path] = struct{}{}
+		files = append(files, path)
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			if !opts.supports(path) {
+				if opts.skipped != nil {
+					opts.skipped(path, "unsupported file type")
+				}
+				continue
+			}
+			add(filepath.Clean(path))
+			continue
+		}
+
+		found, err := discoverAll(path, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range found {
+			add(file)
+		}
+	}
+
+	for _, path := range listed {
+		if !opts.supports(path) {
+			if opts.skipped != nil {
+				opts.skipped(path, "unsupported file type")
+			}
+			continue
+		}
+		add(filepath.Clean(path))
 	}
 
 	sort.Strings(files)
 	return files, nil
 }
+
+// supports reports whether discovery with opts would return a file named
+// like path.
+func (opts discoverOptions) supports(path string) bool {
+	name := filepath.Base(path)
+	return isTerraformFile(name) || (opts.terragrunt && isTerragruntFile(name)) || (opts.markdown && isMarkdownFile(name))
+}
+
+// readFileList reads a list of paths separated by newlines, or by NUL bytes
+// when nul is set. Empty entries are skipped.
+func readFileList(r io.Reader, nul bool) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sep := "\n"
+	if nul {
+		sep = "\x00"
+	}
+
+	paths := make([]string, 0)
+	for _, entry := range strings.Split(string(data), sep) {
+		if !nul {
+			entry = strings.TrimRight(entry, "\r")
+		}
+		if entry == "" {
+			continue
+		}
+		paths = append(paths, entry)
+	}
+
+	return paths, nil
+}
+
+// discoverAll walks dir for Terraform files and the optional kinds enabled in
+// opts, applying its include and exclude globs.
+func discoverAll(dir string, opts discoverOptions) ([]string, error) {
+	files, err := walkFiles(dir, opts, opts.supports)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover files: %w", err)
+	}
+	return files, nil
+}
+
+// discoverFiles returns the Terraform files under dir.
+func discoverFiles(dir string) ([]string, error) {
+	return walkFiles(dir, discoverOptions{}, isTerraformFile)
+}
+
+// isTerraformFile reports whether name has an extension tftidy processes:
+// Terraform's .tf and OpenTofu's .tofu, in native or JSON syntax.
+func isTerraformFile(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".tf") || strings.HasSuffix(lower, ".tofu") || isJSONFile(name)
+}
+
+// isJSONFile reports whether name is a JSON-syntax Terraform file.
+func isJSONFile(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".tf.json") || strings.HasSuffix(lower, ".tofu.json")
+}
+
+// isTerragruntFile reports whether name is a Terragrunt configuration file.
+func isTerragruntFile(name string) bool {
+	return filepath.Base(name) == "terragrunt.hcl"
+}
+
+// isMarkdownFile reports whether name is a Markdown document.
+func isMarkdownFile(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".md")
+}
+
+// splitShadowedFiles separates the .tf and .tf.json files that OpenTofu
+// ignores because a .tofu or .tofu.json file with the same base name exists in
+// the same directory. The override is looked up on disk, so a file is shadowed
+// even when its override is not among files. shadowedBy maps each shadowed
+// file to its replacement.
+func splitShadowedFiles(files []string) ([]string, map[string]string) {
+	loaded := make([]string, 0, len(files))
+	shadowedBy := make(map[string]string)
+	for _, path := range files {
+		if tofu := tofuOverrideFor(path); tofu != "" {
+			if info, err := os.Stat(tofu); err == nil && !info.IsDir() {
+				shadowedBy[path] = tofu
+				continue
+			}
+		}
+		loaded = append(loaded, path)
+	}
+
+	return loaded, shadowedBy
+}
+
+// tofuOverrideFor returns the OpenTofu file that would shadow path, or "" when
+// path is not a Terraform file.
+func tofuOverrideFor(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".tf.json"):
+		return strings.TrimSuffix(path, ".tf.json") + ".tofu.json"
+	case strings.HasSuffix(path, ".tf"):
+		return strings.TrimSuffix(path, ".tf") + ".tofu"
+	default:
+		return ""
+	}
+}
+
+// nativeSyntaxFiles drops JSON-syntax files for commands that only understand
+// native HCL syntax.
+func nativeSyntaxFiles(files []string) []string {
+	result := make([]string, 0, len(files))
+	for _, path := range files {
+		if !isJSONFile(path) {
+			result = append(result, path)
+		}
+	}
+	return result
+}
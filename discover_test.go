@@ -64,6 +64,88 @@ func TestDiscoverFilesRespectsGitignore(t *testing.T) {
 	}
 }
 
+func TestDiscoverFilesIncludesTofuAndJSON(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	for _, name := range []string{"main.tf", "main.tofu", "gen.tf.json", "gen.tofu.json", "package.json", "notes.txt"} {
+		mustWriteFile(t, filepath.Join(tempDir, name), "{}\n", 0o644)
+	}
+
+	files, err := discoverFiles(tempDir)
+	if err != nil {
+		t.Fatalf("discoverFiles failed: %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(tempDir, "gen.tf.json"),
+		filepath.Join(tempDir, "gen.tofu.json"),
+		filepath.Join(tempDir, "main.tf"),
+		filepath.Join(tempDir, "main.tofu"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("unexpected files\nexpected: %#v\nactual: %#v", expected, files)
+	}
+}
+
+func TestSplitShadowedFiles(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	a := filepath.Join(tempDir, "a")
+	b := filepath.Join(tempDir, "b")
+	c := filepath.Join(tempDir, "c")
+	for _, dir := range []string{a, b, c} {
+		mustMkdirAll(t, dir)
+	}
+	for _, path := range []string{
+		filepath.Join(a, "gen.tf.json"),
+		filepath.Join(a, "gen.tofu.json"),
+		filepath.Join(a, "main.tf"),
+		filepath.Join(a, "main.tofu"),
+		filepath.Join(a, "other.tf"),
+		filepath.Join(b, "main.tf"),
+		filepath.Join(c, "main.tf"),
+		filepath.Join(c, "main.tofu"),
+	} {
+		mustWriteFile(t, path, "", 0o644)
+	}
+
+	// c/main.tofu is not in the list, as when only c/main.tf changed, but
+	// it still shadows c/main.tf.
+	files := []string{
+		filepath.Join(a, "gen.tf.json"),
+		filepath.Join(a, "gen.tofu.json"),
+		filepath.Join(a, "main.tf"),
+		filepath.Join(a, "main.tofu"),
+		filepath.Join(a, "other.tf"),
+		filepath.Join(b, "main.tf"),
+		filepath.Join(c, "main.tf"),
+	}
+
+	loaded, shadowedBy := splitShadowedFiles(files)
+
+	expectedLoaded := []string{
+		filepath.Join(a, "gen.tofu.json"),
+		filepath.Join(a, "main.tofu"),
+		filepath.Join(a, "other.tf"),
+		filepath.Join(b, "main.tf"),
+	}
+	if !reflect.DeepEqual(loaded, expectedLoaded) {
+		t.Fatalf("unexpected loaded files\nexpected: %#v\nactual: %#v", expectedLoaded, loaded)
+	}
+
+	expectedShadowed := map[string]string{
+		filepath.Join(a, "gen.tf.json"): filepath.Join(a, "gen.tofu.json"),
+		filepath.Join(a, "main.tf"):     filepath.Join(a, "main.tofu"),
+		filepath.Join(c, "main.tf"):     filepath.Join(c, "main.tofu"),
+	}
+	if !reflect.DeepEqual(shadowedBy, expectedShadowed) {
+		t.Fatalf("unexpected shadowed files\nexpected: %#v\nactual: %#v", expectedShadowed, shadowedBy)
+	}
+}
+
 func mustMkdirAll(t *testing.T, dir string) {
 	t.Helper()
 	if err := os.MkdirAll(dir, 0o755); err != nil {
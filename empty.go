@@ -0,0 +1,58 @@
+package tftidy
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// Values of --delete-empty. deleteEmptyBlank deletes files left with only
+// whitespace; deleteEmptyComments also deletes files left with only comments.
+const (
+	deleteEmptyBlank    = "blank"
+	deleteEmptyComments = "comments"
+)
+
+func parseDeleteEmpty(raw string) (string, error) {
+	switch raw {
+	case "", deleteEmptyBlank, deleteEmptyComments:
+		return raw, nil
+	}
+	return "", fmt.Errorf("invalid --delete-empty value %q (want %q or %q)", raw, deleteEmptyBlank, deleteEmptyComments)
+}
+
+// isEmptyFile reports whether content, the cleaned content of filename, has
+// nothing left but whitespace, or with allowComments nothing but comments. A
+// JSON file is empty when its top-level object has no properties. Terragrunt
+// and Markdown files are never empty, since only embedded code is cleaned.
+func isEmptyFile(content []byte, filename string, allowComments bool) bool {
+	switch {
+	case isJSONFile(filename):
+		_, _, members, err := parseJSONMembers(content)
+		return err == nil && len(members) == 0
+	case isTerragruntFile(filename) || isMarkdownFile(filename):
+		return false
+	}
+
+	if len(bytes.TrimSpace(content)) == 0 {
+		return true
+	}
+	if !allowComments {
+		return false
+	}
+
+	tokens, diags := hclsyntax.LexConfig(content, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return false
+	}
+	for _, token := range tokens {
+		switch token.Type {
+		case hclsyntax.TokenComment, hclsyntax.TokenNewline, hclsyntax.TokenEOF:
+		default:
+			return false
+		}
+	}
+	return true
+}
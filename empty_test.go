@@ -0,0 +1,166 @@
+package tftidy
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsEmptyFile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		filename      string
+		content       string
+		allowComments bool
+		want          bool
+	}{
+		{name: "no content", filename: "moved.tf", content: "", want: true},
+		{name: "whitespace", filename: "moved.tf", content: "\n  \n\t\n", want: true},
+		{name: "comment", filename: "moved.tf", content: "# Moved blocks\n", want: false},
+		{name: "comments allowed", filename: "moved.tf", content: "# Moved blocks\n\n// more\n/* block */\n", allowComments: true, want: true},
+		{name: "attribute", filename: "main.tf", content: "# header\nlocals {}\n", allowComments: true, want: false},
+		{name: "empty json object", filename: "moved.tf.json", content: "{\n}\n", want: true},
+		{name: "json with members", filename: "main.tf.json", content: "{\"locals\": {}}\n", want: false},
+		{name: "terragrunt", filename: "terragrunt.hcl", content: "\n", want: false},
+		{name: "markdown", filename: "README.md", content: "\n", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := isEmptyFile([]byte(tt.content), tt.filename, tt.allowComments); got != tt.want {
+				t.Fatalf("isEmptyFile(%q) = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDeleteEmpty(t *testing.T) {
+	t.Parallel()
+
+	for _, raw := range []string{"", "blank", "comments"} {
+		if got, err := parseDeleteEmpty(raw); err != nil || got != raw {
+			t.Fatalf("parseDeleteEmpty(%q) = %q, %v", raw, got, err)
+		}
+	}
+	if _, err := parseDeleteEmpty("all"); err == nil {
+		t.Fatal("expected error for unknown value")
+	}
+}
+
+func TestIntegrationRunDeleteEmpty(t *testing.T) {
+	t.Parallel()
+
+	moved := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+	commented := "# --- Moved blocks ---\n\n" + moved
+	main := "resource \"aws_instance\" \"main\" {}\n\n" + moved
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantDeleted []string
+		wantStats   []string
+	}{
+		{
+			name:        "blank",
+			args:        []string{"--delete-empty"},
+			wantDeleted: []string{"moved.tf"},
+			wantStats:   []string{"Files modified: 2", "Files deleted: 1", "total:   3"},
+		},
+		{
+			name:        "comments",
+			args:        []string{"--delete-empty=comments"},
+			wantDeleted: []string{"moved.tf", "header.tf"},
+			wantStats:   []string{"Files modified: 1", "Files deleted: 2", "total:   3"},
+		},
+		{
+			name:      "dry run",
+			args:      []string{"--delete-empty=comments", "--dry-run"},
+			wantStats: []string{"Files modified: 1", "Files deleted: 2", "total:   3"},
+		},
+		{
+			name:        "atomic run",
+			args:        []string{"--delete-empty", "--atomic-run"},
+			wantDeleted: []string{"moved.tf"},
+			wantStats:   []string{"Files modified: 2", "Files deleted: 1", "total:   3"},
+		},
+		{
+			name:      "disabled",
+			wantStats: []string{"Files modified: 3", "Files deleted: 0", "total:   3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			mustWriteFile(t, filepath.Join(tempDir, "moved.tf"), moved, 0o644)
+			mustWriteFile(t, filepath.Join(tempDir, "header.tf"), commented, 0o644)
+			mustWriteFile(t, filepath.Join(tempDir, "main.tf"), main, 0o644)
+
+			var stdout bytes.Buffer
+			var stderr bytes.Buffer
+			code := run(append(tt.args, tempDir), &stdout, &stderr)
+			if code != 0 {
+				t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+			}
+			for _, want := range tt.wantStats {
+				if !strings.Contains(stdout.String(), want) {
+					t.Fatalf("missing %q in stats:\n%s", want, stdout.String())
+				}
+			}
+
+			deleted := make(map[string]bool, len(tt.wantDeleted))
+			for _, name := range tt.wantDeleted {
+				deleted[name] = true
+			}
+			for _, name := range []string{"moved.tf", "header.tf", "main.tf"} {
+				_, err := os.Stat(filepath.Join(tempDir, name))
+				if gone := errors.Is(err, fs.ErrNotExist); gone != deleted[name] {
+					t.Fatalf("%s: deleted = %v, want %v (err %v)", name, gone, deleted[name], err)
+				}
+			}
+		})
+	}
+}
+
+func TestIntegrationRunDeleteEmptyKeepsSymlinks(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	target := filepath.Join(tempDir, "shared", "moved.tf")
+	mustMkdirAll(t, filepath.Dir(target))
+	mustWriteFile(t, target, "moved {\n  from = a.b\n  to   = a.c\n}\n", 0o644)
+	module := filepath.Join(tempDir, "module")
+	mustMkdirAll(t, module)
+	link := filepath.Join(module, "moved.tf")
+	mustSymlink(t, target, link)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--delete-empty", "--follow-symlinks", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Files deleted: 0") {
+		t.Fatalf("unexpected stats:\n%s", stdout.String())
+	}
+	if _, err := os.Lstat(link); err != nil {
+		t.Fatalf("symlink must not be deleted: %v", err)
+	}
+	content, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("failed to read target: %v", err)
+	}
+	if string(content) != "" {
+		t.Fatalf("target should be emptied: %q", string(content))
+	}
+}
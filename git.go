@@ -0,0 +1,120 @@
+package tftidy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// changedFilesOptions selects which local changes count as changed in
+// addition to the commits since the merge base with the ref.
+type changedFilesOptions struct {
+	staged    bool
+	untracked bool
+}
+
+// changedFiles asks the git repository containing dir which files changed on
+// the current branch since it diverged from ref. It returns absolute paths;
+// deleted files are left out because there is nothing to clean in them.
+func changedFiles(dir, ref string, opts changedFilesOptions) (map[string]struct{}, error) {
+	root, err := gitOutput(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root = strings.TrimSpace(root)
+
+	queries := [][]string{
+		{"diff", "--name-only", "-z", "--diff-filter=d", ref + "...HEAD", "--"},
+	}
+	if opts.staged {
+		queries = append(queries, []string{"diff", "--cached", "--name-only", "-z", "--diff-filter=d", "--"})
+	}
+	if opts.untracked {
+		queries = append(queries, []string{"ls-files", "--others", "--exclude-standard", "-z", "--full-name", "--", "."})
+	}
+
+	changed := make(map[string]struct{})
+	for _, query := range queries {
+		out, err := gitOutput(root, query...)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range strings.Split(out, "\x00") {
+			if name == "" {
+				continue
+			}
+			changed[filepath.Join(root, filepath.FromSlash(name))] = struct{}{}
+		}
+	}
+
+	return changed, nil
+}
+
+// filterChangedFiles keeps the files that git reports as changed since ref.
+func filterChangedFiles(files []string, dir, ref string, opts changedFilesOptions) ([]string, error) {
+	changed, err := changedFiles(dir, ref, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make([]string, 0, len(files))
+	for _, path := range files {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		// git reports paths under the resolved top-level directory.
+		if resolvedDir, err := filepath.EvalSymlinks(filepath.Dir(absPath)); err == nil {
+			absPath = filepath.Join(resolvedDir, filepath.Base(absPath))
+		}
+		if _, ok := changed[absPath]; ok {
+			kept = append(kept, path)
+		}
+	}
+
+	return kept, nil
+}
+
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+
+	return stdout.String(), nil
+}
+
+// gitTopLevel returns the root of the git work tree containing dir.
+func gitTopLevel(dir string) (string, error) {
+	out, err := gitOutput(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// gitWorkDir picks the directory to run git in: the first path argument, or
+// its parent when it is a file, or the current directory.
+func gitWorkDir(paths []string) string {
+	if len(paths) == 0 {
+		return "."
+	}
+	if info, err := os.Stat(paths[0]); err == nil && !info.IsDir() {
+		return filepath.Dir(paths[0])
+	}
+	return paths[0]
+}
@@ -0,0 +1,141 @@
+package tftidy
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIntegrationRunChangedSince(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	tempDir := t.TempDir()
+	moved := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+
+	committed := filepath.Join(tempDir, "committed.tf")
+	unchanged := filepath.Join(tempDir, "unchanged.tf")
+	staged := filepath.Join(tempDir, "staged.tf")
+	untracked := filepath.Join(tempDir, "untracked.tf")
+
+	mustGit(t, tempDir, "init", "-q")
+	mustWriteFile(t, committed, "locals {}\n", 0o644)
+	mustWriteFile(t, unchanged, moved, 0o644)
+	mustGit(t, tempDir, "add", ".")
+	mustGit(t, tempDir, "commit", "-q", "-m", "base")
+	mustGit(t, tempDir, "tag", "base")
+
+	mustWriteFile(t, committed, "locals {}\n\n"+moved, 0o644)
+	mustGit(t, tempDir, "commit", "-q", "-am", "change")
+	mustWriteFile(t, staged, moved, 0o644)
+	mustGit(t, tempDir, "add", staged)
+	mustWriteFile(t, untracked, moved, 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--changed-since", "base", "--verbose", "--dry-run", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if got := processedFiles(stdout.String()); strings.Join(got, ",") != committed {
+		t.Fatalf("only committed changes should be processed, got %v", got)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"--changed-since", "base", "--include-staged", "--include-untracked", "--verbose", "--dry-run", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	expected := []string{committed, staged, untracked}
+	if got := processedFiles(stdout.String()); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Fatalf("unexpected processed files\nexpected: %v\nactual: %v", expected, got)
+	}
+}
+
+func TestIntegrationRunChangedSinceUnknownRef(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	tempDir := t.TempDir()
+	mustGit(t, tempDir, "init", "-q")
+	mustWriteFile(t, filepath.Join(tempDir, "main.tf"), "locals {}\n", 0o644)
+	mustGit(t, tempDir, "add", ".")
+	mustGit(t, tempDir, "commit", "-q", "-m", "base")
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--changed-since", "does-not-exist", tempDir}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "failed to list changed files") {
+		t.Fatalf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+func mustGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=tftidy", "-c", "user.email=tftidy@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+func processedFiles(out string) []string {
+	files := make([]string, 0)
+	for _, line := range strings.Split(out, "\n") {
+		if path, ok := strings.CutPrefix(line, "Processing: "); ok {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+func TestIntegrationRunChangedSinceProtectChildModules(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	tempDir := t.TempDir()
+	moved := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+	mustMkdirAll(t, filepath.Join(tempDir, "child"))
+	childFile := filepath.Join(tempDir, "child", "main.tf")
+
+	mustGit(t, tempDir, "init", "-q")
+	mustWriteFile(t, filepath.Join(tempDir, "main.tf"), "module \"child\" {\n  source = \"./child\"\n}\n", 0o644)
+	mustWriteFile(t, childFile, "locals {}\n", 0o644)
+	mustGit(t, tempDir, "add", ".")
+	mustGit(t, tempDir, "commit", "-q", "-m", "base")
+	mustGit(t, tempDir, "tag", "base")
+
+	mustWriteFile(t, childFile, "locals {}\n\n"+moved, 0o644)
+	mustGit(t, tempDir, "commit", "-q", "-am", "change")
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--changed-since", "base", "--protect-child-modules", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+
+	content, err := os.ReadFile(childFile)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if !containsBlockDeclaration(string(content), "moved") {
+		t.Fatalf("moved block in a child module whose caller did not change must be kept:\n%s", string(content))
+	}
+}
@@ -5,9 +5,11 @@ go 1.24
 toolchain go1.26.5
 
 require (
+	github.com/bmatcuk/doublestar/v4 v4.10.2
 	github.com/boyter/gocodewalker v1.5.1
 	github.com/hashicorp/hcl/v2 v2.24.0
 	github.com/spf13/pflag v1.0.10
+	github.com/zclconf/go-cty v1.16.3
 )
 
 require (
@@ -16,7 +18,6 @@ require (
 	github.com/danwakefield/fnmatch v0.0.0-20160403171240-cbb64ac3d964 // indirect
 	github.com/google/go-cmp v0.6.0 // indirect
 	github.com/mitchellh/go-wordwrap v1.0.1 // indirect
-	github.com/zclconf/go-cty v1.16.3 // indirect
 	golang.org/x/mod v0.17.0 // indirect
 	golang.org/x/sync v0.14.0 // indirect
 	golang.org/x/text v0.25.0 // indirect
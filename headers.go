@@ -0,0 +1,117 @@
+package tftidy
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// paragraph is a run of non-blank lines; blank lines inside a block do not
+// end it. comments marks a paragraph of top-level line comments only, and
+// removed a paragraph whose items are all removed blocks.
+type paragraph struct {
+	rng      byteRange
+	comments bool
+	removed  bool
+}
+
+// orphanedHeaderRanges returns the byte ranges of comment paragraphs, such as
+// "# --- Moved blocks ---", that head only blocks of blockTypes: every
+// paragraph between the header and the next comment paragraph or the end of
+// the file holds nothing but those blocks and their comments. Removing the
+// blocks would leave such a header describing nothing.
+func orphanedHeaderRanges(content []byte, filename string, blockTypes []string) ([]byteRange, error) {
+	syntaxFile, diags := hclsyntax.ParseConfig(content, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse %s: %s", filename, diags.Error())
+	}
+	syntaxBody, ok := syntaxFile.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("unexpected HCL body type in %s", filename)
+	}
+
+	typeSet := make(map[string]struct{}, len(blockTypes))
+	for _, blockType := range blockTypes {
+		typeSet[blockType] = struct{}{}
+	}
+
+	// itemAt maps each line covered by a top-level item to whether the item
+	// is removed.
+	itemAt := make(map[int]bool)
+	mark := func(rng hcl.Range, removed bool) {
+		for line := rng.Start.Line; line <= rng.End.Line; line++ {
+			itemAt[line] = removed
+		}
+	}
+	for _, attr := range syntaxBody.Attributes {
+		mark(attr.SrcRange, false)
+	}
+	for _, block := range syntaxBody.Blocks {
+		_, removed := typeSet[block.Type]
+		mark(block.Range(), removed)
+	}
+
+	paragraphs := make([]paragraph, 0)
+	var current *paragraph
+	offset := 0
+	for lineNo := 1; offset < len(content); lineNo++ {
+		end := len(content)
+		if i := bytes.IndexByte(content[offset:], '\n'); i >= 0 {
+			end = offset + i
+		}
+		line := strings.TrimSpace(string(content[offset:end]))
+		removed, inItem := itemAt[lineNo]
+
+		if line == "" && !inItem {
+			current = nil
+		} else {
+			if current == nil {
+				paragraphs = append(paragraphs, paragraph{rng: byteRange{start: offset}, comments: true, removed: true})
+				current = &paragraphs[len(paragraphs)-1]
+			}
+			current.rng.end = end
+			isComment := !inItem && (strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//"))
+			current.comments = current.comments && isComment
+			current.removed = current.removed && (isComment || removed)
+		}
+		offset = end + 1
+	}
+
+	ranges := make([]byteRange, 0)
+	for i, p := range paragraphs {
+		if !p.comments {
+			continue
+		}
+
+		heads := 0
+		orphaned := true
+		for _, next := range paragraphs[i+1:] {
+			if next.comments {
+				break
+			}
+			if !next.removed {
+				orphaned = false
+				break
+			}
+			heads++
+		}
+		if orphaned && heads > 0 {
+			// The blank lines below the header go with it.
+			ranges = append(ranges, byteRange{start: p.rng.start, end: paragraphs[i+1].rng.start})
+		}
+	}
+
+	return ranges, nil
+}
+
+// removeOrphanedHeaders removes the headers orphanedHeaderRanges finds.
+func removeOrphanedHeaders(content []byte, filename string, blockTypes []string) ([]byte, error) {
+	headers, err := orphanedHeaderRanges(content, filename, blockTypes)
+	if err != nil {
+		return nil, err
+	}
+	return removeByteRanges(content, headers), nil
+}
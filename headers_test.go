@@ -0,0 +1,154 @@
+package tftidy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOrphanedHeaderRanges(t *testing.T) {
+	t.Parallel()
+
+	moved := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+	resource := "resource \"aws_instance\" \"main\" {\n  ami = \"ami-123456\"\n\n  tags = {}\n}\n"
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "header before removed blocks only",
+			content: "# --- Moved blocks ---\n\n" + moved + "\n" + moved,
+			want:    []string{"# --- Moved blocks ---"},
+		},
+		{
+			name:    "header before kept block",
+			content: "# --- Moved blocks ---\n\n" + moved + "\n" + resource,
+			want:    []string{},
+		},
+		{
+			name:    "next section header",
+			content: "# --- Moved blocks ---\n\n" + moved + "\n# --- Resources ---\n\n" + resource,
+			want:    []string{"# --- Moved blocks ---"},
+		},
+		{
+			name:    "file header before section header",
+			content: "# Managed by platform team.\n\n// --- Moved blocks ---\n// Safe to delete after apply.\n\n" + moved,
+			want:    []string{"// --- Moved blocks ---\n// Safe to delete after apply."},
+		},
+		{
+			name:    "header attached to block",
+			content: "# --- Moved blocks ---\n" + moved,
+			want:    []string{},
+		},
+		{
+			name:    "header without blocks",
+			content: "# --- Moved blocks ---\n\n" + resource,
+			want:    []string{},
+		},
+		{
+			name:    "attribute after removed blocks",
+			content: "# --- Moved blocks ---\n\n" + moved + "\nlocals_enabled = true\n",
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			content := []byte(tt.content)
+			ranges, err := orphanedHeaderRanges(content, "main.tf", []string{"moved"})
+			if err != nil {
+				t.Fatalf("orphanedHeaderRanges failed: %v", err)
+			}
+
+			got := make([]string, 0, len(ranges))
+			for _, r := range ranges {
+				got = append(got, strings.TrimSpace(string(content[r.start:r.end])))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("unexpected headers: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveBlocksOrphanedHeaders(t *testing.T) {
+	t.Parallel()
+
+	input := `# --- Resources ---
+
+resource "aws_instance" "main" {
+  ami = "ami-123456"
+}
+
+# --- Moved blocks ---
+
+moved {
+  from = aws_instance.old
+  to   = aws_instance.main
+}
+
+# --- Imports ---
+
+import {
+  to = aws_instance.main
+  id = "i-123456"
+}
+`
+
+	tests := []struct {
+		name           string
+		removeComments bool
+		wantHeader     bool
+	}{
+		{name: "remove comments", removeComments: true, wantHeader: false},
+		{name: "preserve comments", removeComments: false, wantHeader: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			output, counts, err := removeBlocks([]byte(input), "main.tf", []string{"moved"}, tt.removeComments)
+			if err != nil {
+				t.Fatalf("removeBlocks failed: %v", err)
+			}
+			if counts["moved"] != 1 {
+				t.Fatalf("unexpected counts: %v", counts)
+			}
+
+			outputStr := string(output)
+			if got := strings.Contains(outputStr, "# --- Moved blocks ---"); got != tt.wantHeader {
+				t.Fatalf("header kept = %v, want %v:\n%s", got, tt.wantHeader, outputStr)
+			}
+			for _, kept := range []string{"# --- Resources ---", "# --- Imports ---", "import {"} {
+				if !strings.Contains(outputStr, kept) {
+					t.Fatalf("%q should remain:\n%s", kept, outputStr)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveBlocksOrphanedHeadersEmbedded(t *testing.T) {
+	t.Parallel()
+
+	markdown := "# Upgrade notes\n\n```hcl\n# --- Moved blocks ---\n\nmoved {\n  from = a.b\n  to   = a.c\n}\n```\n"
+
+	output, counts, err := removeBlocks([]byte(markdown), "README.md", []string{"moved"}, true)
+	if err != nil {
+		t.Fatalf("removeBlocks failed: %v", err)
+	}
+	if counts["moved"] != 1 {
+		t.Fatalf("unexpected counts: %v", counts)
+	}
+	if strings.Contains(string(output), "--- Moved blocks ---") {
+		t.Fatalf("orphaned header should be removed from the fence:\n%s", string(output))
+	}
+	if !strings.Contains(string(output), "# Upgrade notes") {
+		t.Fatalf("Markdown heading should remain:\n%s", string(output))
+	}
+}
@@ -410,3 +410,78 @@ resource "aws_s3_bucket" "data" {
 		t.Fatalf("non-target resource should remain:\n%s", result)
 	}
 }
+
+func TestIntegrationRunSkipShadowed(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	input := `moved {
+  from = aws_instance.old
+  to   = aws_instance.main
+}
+`
+	tfFile := filepath.Join(tempDir, "main.tf")
+	tofuFile := filepath.Join(tempDir, "main.tofu")
+	if err := os.WriteFile(tfFile, []byte(input), 0o644); err != nil {
+		t.Fatalf("failed to write main.tf: %v", err)
+	}
+	if err := os.WriteFile(tofuFile, []byte(input), 0o644); err != nil {
+		t.Fatalf("failed to write main.tofu: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--skip-shadowed", "--verbose", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+
+	out := stdout.String()
+	if !strings.Contains(out, "Skipping shadowed: "+tfFile+" (shadowed by "+tofuFile+")") {
+		t.Fatalf("shadowed file should be reported:\n%s", out)
+	}
+
+	tfContent, err := os.ReadFile(tfFile)
+	if err != nil {
+		t.Fatalf("failed to read main.tf: %v", err)
+	}
+	if string(tfContent) != input {
+		t.Fatalf("shadowed file must not be modified:\n%s", string(tfContent))
+	}
+
+	tofuContent, err := os.ReadFile(tofuFile)
+	if err != nil {
+		t.Fatalf("failed to read main.tofu: %v", err)
+	}
+	if containsBlockDeclaration(string(tofuContent), "moved") {
+		t.Fatalf("moved block should be removed from main.tofu:\n%s", string(tofuContent))
+	}
+}
+
+func TestIntegrationRunSkipShadowedFileArgument(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	input := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+	tfFile := filepath.Join(tempDir, "main.tf")
+	mustWriteFile(t, tfFile, input, 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "main.tofu"), input, 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--skip-shadowed", tfFile}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Files processed: 0") {
+		t.Fatalf("shadowed file argument should be skipped:\n%s", stdout.String())
+	}
+
+	content, err := os.ReadFile(tfFile)
+	if err != nil {
+		t.Fatalf("failed to read main.tf: %v", err)
+	}
+	if string(content) != input {
+		t.Fatalf("shadowed file must not be modified:\n%s", string(content))
+	}
+}
@@ -0,0 +1,194 @@
+package tftidy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// jsonMember is a top-level property of a JSON-syntax Terraform file. start
+// is the offset of its key's opening quote and end the offset just past its
+// value.
+type jsonMember struct {
+	key   string
+	start int
+	end   int
+	value json.RawMessage
+}
+
+// removeJSONBlocks removes the top-level properties of the given block types
+// from a .tf.json document. Everything else, including key order and
+// indentation, is kept byte for byte.
+func removeJSONBlocks(content []byte, filename string, blockTypes []string) ([]byte, map[string]int, error) {
+	openBrace, closeBrace, members, err := parseJSONMembers(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	typeSet := make(map[string]struct{}, len(blockTypes))
+	for _, blockType := range blockTypes {
+		typeSet[blockType] = struct{}{}
+	}
+
+	counts := make(map[string]int, len(blockTypes))
+	kept := make([]int, 0, len(members))
+	for i, member := range members {
+		if _, ok := typeSet[member.key]; !ok {
+			kept = append(kept, i)
+			continue
+		}
+
+		n, err := countJSONBlocks(member.value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %s: %w", filename, member.key, err)
+		}
+		counts[member.key] += n
+	}
+
+	if len(kept) == len(members) {
+		return content, map[string]int{}, nil
+	}
+
+	var buf bytes.Buffer
+	if len(kept) == 0 {
+		buf.Write(content[:openBrace+1])
+		buf.Write(content[closeBrace:])
+		return buf.Bytes(), counts, nil
+	}
+
+	// Each kept member is preceded by the separator it originally had, so
+	// indentation and line breaks stay as they were.
+	buf.Write(content[:members[0].start])
+	for j, i := range kept {
+		if j > 0 {
+			buf.Write(content[members[i-1].end:members[i].start])
+		}
+		buf.Write(content[members[i].start:members[i].end])
+	}
+	buf.Write(content[members[len(members)-1].end:])
+
+	return buf.Bytes(), counts, nil
+}
+
+// collectJSONBlocks returns the blocks removeJSONBlocks would remove: one per
+// object-form property and one per element of an array-form property. The
+// source of each block is its JSON object text.
+func collectJSONBlocks(content []byte, filename string, blockTypes []string) ([]*transientBlock, error) {
+	_, _, members, err := parseJSONMembers(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	typeSet := make(map[string]struct{}, len(blockTypes))
+	for _, blockType := range blockTypes {
+		typeSet[blockType] = struct{}{}
+	}
+
+	blocks := make([]*transientBlock, 0)
+	for _, member := range members {
+		if _, ok := typeSet[member.key]; !ok {
+			continue
+		}
+
+		elems := []json.RawMessage{member.value}
+		if trimmed := bytes.TrimSpace(member.value); len(trimmed) > 0 && trimmed[0] == '[' {
+			if err := json.Unmarshal(trimmed, &elems); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %s: %w", filename, member.key, err)
+			}
+		}
+
+		addressAttr := "from"
+		if member.key == "import" {
+			addressAttr = "to"
+		}
+		line := 1 + bytes.Count(content[:member.start], []byte("\n"))
+		for _, elem := range elems {
+			var attrs map[string]json.RawMessage
+			address := ""
+			if json.Unmarshal(elem, &attrs) == nil {
+				_ = json.Unmarshal(attrs[addressAttr], &address)
+			}
+			blocks = append(blocks, &transientBlock{
+				path:      filename,
+				blockType: member.key,
+				rng:       hcl.Range{Filename: filename, Start: hcl.Pos{Line: line}, End: hcl.Pos{Line: line}},
+				source:    bytes.TrimSpace(elem),
+				address:   address,
+			})
+		}
+	}
+
+	return blocks, nil
+}
+
+// parseJSONMembers returns the offsets of the top-level object's braces and
+// its members in document order.
+func parseJSONMembers(content []byte) (int, int, []jsonMember, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+
+	tok, err := dec.Token()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return 0, 0, nil, fmt.Errorf("top-level value must be an object")
+	}
+	openBrace := int(dec.InputOffset()) - 1
+
+	members := make([]jsonMember, 0)
+	for dec.More() {
+		prevEnd := int(dec.InputOffset())
+
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return 0, 0, nil, fmt.Errorf("unexpected token %v", tok)
+		}
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return 0, 0, nil, err
+		}
+
+		members = append(members, jsonMember{
+			key:   key,
+			start: prevEnd + bytes.IndexByte(content[prevEnd:], '"'),
+			end:   int(dec.InputOffset()),
+			value: value,
+		})
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return 0, 0, nil, err
+	}
+	closeBrace := int(dec.InputOffset()) - 1
+
+	return openBrace, closeBrace, members, nil
+}
+
+// countJSONBlocks returns how many blocks a block-type property holds: one
+// for the object form and one per element for the array form.
+func countJSONBlocks(value json.RawMessage) (int, error) {
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) == 0 {
+		return 0, fmt.Errorf("empty value")
+	}
+
+	switch trimmed[0] {
+	case '{':
+		return 1, nil
+	case '[':
+		var elems []json.RawMessage
+		if err := json.Unmarshal(trimmed, &elems); err != nil {
+			return 0, err
+		}
+		return len(elems), nil
+	default:
+		return 0, fmt.Errorf("expected an object or an array of objects")
+	}
+}
@@ -0,0 +1,126 @@
+package tftidy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemoveJSONBlocks(t *testing.T) {
+	t.Parallel()
+
+	input := `{
+  "resource": {
+    "aws_instance": {
+      "main": {"ami": "ami-123456"}
+    }
+  },
+  "moved": [
+    {"from": "aws_instance.old1", "to": "aws_instance.main"},
+    {"from": "aws_instance.old2", "to": "aws_instance.other"}
+  ],
+  "output": {
+    "id": {"value": "${aws_instance.main.id}"}
+  },
+  "import": {"to": "aws_instance.main", "id": "i-123"}
+}
+`
+
+	output, counts, err := removeBlocks([]byte(input), "main.tf.json", []string{"moved", "import"}, false)
+	if err != nil {
+		t.Fatalf("removeBlocks failed: %v", err)
+	}
+	if counts["moved"] != 2 || counts["import"] != 1 {
+		t.Fatalf("unexpected counts: %#v", counts)
+	}
+
+	expected := `{
+  "resource": {
+    "aws_instance": {
+      "main": {"ami": "ami-123456"}
+    }
+  },
+  "output": {
+    "id": {"value": "${aws_instance.main.id}"}
+  }
+}
+`
+	if string(output) != expected {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", expected, string(output))
+	}
+}
+
+func TestRemoveJSONBlocksLeadingAndOnly(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "leading member",
+			input:    "{\n  \"moved\": {\"from\": \"a.b\", \"to\": \"a.c\"},\n  \"locals\": {\"x\": 1}\n}\n",
+			expected: "{\n  \"locals\": {\"x\": 1}\n}\n",
+		},
+		{
+			name:     "only member",
+			input:    "{\n  \"removed\": {\"from\": \"a.b\"}\n}\n",
+			expected: "{}\n",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			output, _, err := removeBlocks([]byte(tc.input), "main.tf.json", allowedBlockTypes, false)
+			if err != nil {
+				t.Fatalf("removeBlocks failed: %v", err)
+			}
+			if string(output) != tc.expected {
+				t.Fatalf("unexpected output\nexpected: %q\nactual:   %q", tc.expected, string(output))
+			}
+		})
+	}
+}
+
+func TestRemoveJSONBlocksInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{`{"moved": }`, `["moved"]`, `{"moved": "x"}`} {
+		if _, _, err := removeBlocks([]byte(input), "main.tf.json", []string{"moved"}, false); err == nil {
+			t.Fatalf("expected parse error for %s", input)
+		}
+	}
+}
+
+func TestIntegrationRunJSONFiles(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "generated.tf.json")
+	mustWriteFile(t, file, "{\n  \"locals\": {\"x\": 1},\n  \"moved\": {\"from\": \"a.b\", \"to\": \"a.c\"}\n}\n", 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "package.json"), "{\"moved\": {}}\n", 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Files processed: 1") {
+		t.Fatalf("only the .tf.json file should be processed:\n%s", stdout.String())
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "{\n  \"locals\": {\"x\": 1}\n}\n" {
+		t.Fatalf("unexpected content: %q", string(content))
+	}
+}
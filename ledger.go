@@ -0,0 +1,143 @@
+package tftidy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultLedgerFile is the conventional ledger location, and where restore
+// reads from unless --ledger is given.
+const defaultLedgerFile = ".tftidy/ledger.jsonl"
+
+// ledgerEntry is one removed block, stored as a line of JSON in the ledger.
+type ledgerEntry struct {
+	RunID     string `json:"run_id"`
+	Timestamp string `json:"timestamp"`
+	Version   string `json:"version"`
+	GitHead   string `json:"git_head,omitempty"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Type      string `json:"type"`
+	Address   string `json:"address,omitempty"`
+	Source    string `json:"source"`
+}
+
+// ledger appends the blocks removed by one run to a JSON Lines file.
+type ledger struct {
+	path    string
+	runID   string
+	gitHead string
+}
+
+// newLedger prepares a ledger for a run. gitDir is where git is asked for the
+// current commit; outside a repository the commit is left out.
+func newLedger(path, gitDir string) (*ledger, error) {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	l := &ledger{
+		path:  path,
+		runID: time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(id),
+	}
+	if head, err := gitOutput(gitDir, "rev-parse", "HEAD"); err == nil {
+		l.gitHead = strings.TrimSpace(head)
+	}
+	return l, nil
+}
+
+// record appends an entry for every block of blockTypes that removal takes out
+// of content, the original content of path.
+func (l *ledger) record(path string, content []byte, blockTypes []string) error {
+	blocks, err := collectRemovedBlocks(content, path, blockTypes)
+	if err != nil {
+		return err
+	}
+
+	file, err := ledgerFilePath(path)
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	var buf bytes.Buffer
+	for _, b := range blocks {
+		line, err := json.Marshal(ledgerEntry{
+			RunID:     l.runID,
+			Timestamp: timestamp,
+			Version:   Version,
+			GitHead:   l.gitHead,
+			File:      file,
+			Line:      b.rng.Start.Line,
+			Type:      b.blockType,
+			Address:   b.address,
+			Source:    string(b.source),
+		})
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ledgerFilePath records path relative to the current directory when it is
+// below it, and as an absolute path otherwise.
+func ledgerFilePath(path string) (string, error) {
+	rel, below, err := relativeToWorkingDir(path)
+	if err != nil || !below {
+		return rel, err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// readLedger returns the entries of the ledger at path in the order they were
+// recorded.
+func readLedger(path string) ([]ledgerEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	entries := make([]ledgerEntry, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry ledgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,138 @@
+package tftidy
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCollectRemovedBlocks(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		filename  string
+		content   string
+		lines     []int
+		addresses []string
+	}{
+		{
+			name:      "native",
+			filename:  "main.tf",
+			content:   "resource \"a\" \"b\" {}\n\nmoved {\n  from = a.old\n  to   = a.b\n}\n\nimport {\n  to = a.b\n  id = \"x\"\n}\n",
+			lines:     []int{3, 8},
+			addresses: []string{"a.old", "a.b"},
+		},
+		{
+			name:      "json",
+			filename:  "main.tf.json",
+			content:   "{\n  \"moved\": [\n    {\"from\": \"a.old\", \"to\": \"a.b\"},\n    {\"from\": \"a.older\", \"to\": \"a.old\"}\n  ]\n}\n",
+			lines:     []int{2, 2},
+			addresses: []string{"a.old", "a.older"},
+		},
+		{
+			name:      "terragrunt",
+			filename:  "terragrunt.hcl",
+			content:   "generate \"moves\" {\n  path     = \"moves.tf\"\n  contents = <<-EOF\n    moved {\n      from = a.old\n      to   = a.b\n    }\n  EOF\n}\n",
+			lines:     []int{4},
+			addresses: []string{"a.old"},
+		},
+		{
+			name:      "markdown",
+			filename:  "README.md",
+			content:   "# Moves\n\n```hcl\nmoved {\n  from = a.old\n  to   = a.b\n}\n```\n\n```hcl\nmoved {\n```\n",
+			lines:     []int{4},
+			addresses: []string{"a.old"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			blocks, err := collectRemovedBlocks([]byte(tt.content), tt.filename, allowedBlockTypes)
+			if err != nil {
+				t.Fatalf("collectRemovedBlocks failed: %v", err)
+			}
+			if len(blocks) != len(tt.lines) {
+				t.Fatalf("expected %d blocks, got %d", len(tt.lines), len(blocks))
+			}
+			for i, b := range blocks {
+				if b.path != tt.filename || b.rng.Start.Line != tt.lines[i] || b.address != tt.addresses[i] {
+					t.Fatalf("unexpected block %d: %s %q", i, b.position(), b.address)
+				}
+				if len(b.source) == 0 {
+					t.Fatalf("block %d has no source", i)
+				}
+			}
+		})
+	}
+}
+
+func TestIntegrationRunLedger(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	mustGit(t, tempDir, "init", "-q")
+	moved := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+	file := filepath.Join(tempDir, "main.tf")
+	mustWriteFile(t, file, "resource \"aws_instance\" \"main\" {}\n\n"+moved, 0o644)
+	mustGit(t, tempDir, "add", "-A")
+	mustGit(t, tempDir, "commit", "-q", "-m", "init")
+	head, err := exec.Command("git", "-C", tempDir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("git rev-parse failed: %v", err)
+	}
+
+	ledgerFile := filepath.Join(tempDir, ".tftidy", "ledger.jsonl")
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--dry-run", "--ledger=" + ledgerFile, tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if _, err := os.Stat(ledgerFile); !os.IsNotExist(err) {
+		t.Fatalf("dry-run must not write the ledger: %v", err)
+	}
+
+	// The ledger path may be given as a separate argument.
+	stdout.Reset()
+	code = run([]string{"--ledger", ledgerFile, tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Files modified: 1") {
+		t.Fatalf("the scanned directory should be processed:\n%s", stdout.String())
+	}
+
+	data, err := os.ReadFile(ledgerFile)
+	if err != nil {
+		t.Fatalf("ledger not written: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected one ledger entry, got:\n%s", string(data))
+	}
+
+	var entry ledgerEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("invalid ledger entry: %v", err)
+	}
+	if entry.File != file || entry.Line != 3 || entry.Type != "moved" || entry.Address != "aws_instance.old" {
+		t.Fatalf("unexpected entry: %#v", entry)
+	}
+	if entry.Source != strings.TrimSuffix(moved, "\n") {
+		t.Fatalf("unexpected source: %q", entry.Source)
+	}
+	if entry.GitHead != strings.TrimSpace(string(head)) || entry.Version != Version {
+		t.Fatalf("unexpected provenance: %#v", entry)
+	}
+	if entry.RunID == "" || entry.Timestamp == "" {
+		t.Fatalf("missing run id or timestamp: %#v", entry)
+	}
+}
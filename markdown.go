@@ -0,0 +1,134 @@
+package tftidy
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// markdownFence is an HCL code fence in a Markdown document. body spans the
+// lines between the opening and closing fence lines.
+type markdownFence struct {
+	body byteRange
+	line int
+}
+
+// fenceLanguages are the info strings that mark a fence as Terraform code.
+var fenceLanguages = map[string]struct{}{
+	"hcl":       {},
+	"terraform": {},
+	"tf":        {},
+}
+
+// removeMarkdownBlocks removes transient blocks from the hcl and terraform
+// code fences of a Markdown document. Fences that do not parse are left as
+// they are instead of failing the whole document.
+func removeMarkdownBlocks(content []byte, filename string, blockTypes []string, removeComments bool) ([]byte, map[string]int, error) {
+	fences := findMarkdownFences(content)
+
+	counts := make(map[string]int, len(blockTypes))
+	result := append([]byte(nil), content...)
+	for i := len(fences) - 1; i >= 0; i-- {
+		f := fences[i]
+		body := result[f.body.start:f.body.end]
+
+		indent := commonIndent(body)
+		label := fmt.Sprintf("%s:%d", filename, f.line)
+		updated, fenceCounts, err := removeBlocks(reindent(body, indent, ""), label, blockTypes, removeComments)
+		if err != nil || sumCounts(fenceCounts) == 0 {
+			continue
+		}
+		updated = reindent(updated, "", indent)
+
+		spliced := make([]byte, 0, len(result)+len(updated)-len(body))
+		spliced = append(spliced, result[:f.body.start]...)
+		spliced = append(spliced, updated...)
+		spliced = append(spliced, result[f.body.end:]...)
+		result = spliced
+
+		for blockType, count := range fenceCounts {
+			counts[blockType] += count
+		}
+	}
+
+	if sumCounts(counts) == 0 {
+		return content, map[string]int{}, nil
+	}
+
+	return result, counts, nil
+}
+
+// collectMarkdownBlocks returns the transient blocks removeMarkdownBlocks
+// would remove, with lines counted in the Markdown document.
+func collectMarkdownBlocks(content []byte, filename string, blockTypes []string) []*transientBlock {
+	blocks := make([]*transientBlock, 0)
+	for _, f := range findMarkdownFences(content) {
+		found, err := collectEmbeddedBlocks(content[f.body.start:f.body.end], filename, f.line, blockTypes)
+		if err != nil {
+			continue
+		}
+		blocks = append(blocks, found...)
+	}
+	return blocks
+}
+
+// findMarkdownFences returns the closed backtick or tilde fences whose info
+// string names hcl, terraform, or tf, in document order.
+func findMarkdownFences(content []byte) []markdownFence {
+	fences := make([]markdownFence, 0)
+
+	var (
+		open      bool
+		marker    string
+		isHCL     bool
+		bodyStart int
+		openLine  int
+	)
+
+	offset := 0
+	for lineNo := 1; offset < len(content); lineNo++ {
+		end := bytes.IndexByte(content[offset:], '\n')
+		next := len(content)
+		if end >= 0 {
+			next = offset + end + 1
+		}
+		line := strings.TrimRight(string(content[offset:next]), "\r\n")
+
+		trimmed := strings.TrimLeft(line, " ")
+		if len(line)-len(trimmed) <= 3 {
+			switch {
+			case !open:
+				if m := fenceMarker(trimmed); m != "" {
+					open = true
+					marker = m
+					info := strings.Fields(strings.TrimPrefix(trimmed, m))
+					isHCL = len(info) > 0
+					if isHCL {
+						_, isHCL = fenceLanguages[strings.ToLower(info[0])]
+					}
+					bodyStart = next
+					openLine = lineNo + 1
+				}
+			case strings.HasPrefix(trimmed, marker) && strings.TrimSpace(strings.TrimLeft(trimmed, marker[:1])) == "":
+				if isHCL {
+					fences = append(fences, markdownFence{body: byteRange{start: bodyStart, end: offset}, line: openLine})
+				}
+				open = false
+			}
+		}
+
+		offset = next
+	}
+
+	return fences
+}
+
+// fenceMarker returns the run of three or more backticks or tildes that opens
+// a fence at the start of line, or "" if line does not open a fence.
+func fenceMarker(line string) string {
+	if !strings.HasPrefix(line, "```") && !strings.HasPrefix(line, "~~~") {
+		return ""
+	}
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	return line[:n]
+}
@@ -0,0 +1,126 @@
+package tftidy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemoveMarkdownBlocks(t *testing.T) {
+	t.Parallel()
+
+	input := "# Upgrade guide\n" +
+		"\n" +
+		"```hcl\n" +
+		"module \"vpc\" {\n" +
+		"  source = \"./vpc\"\n" +
+		"}\n" +
+		"\n" +
+		"moved {\n" +
+		"  from = module.network\n" +
+		"  to   = module.vpc\n" +
+		"}\n" +
+		"```\n" +
+		"\n" +
+		"```terraform\n" +
+		"import {\n" +
+		"  to = ...\n" +
+		"}\n" +
+		"```\n" +
+		"\n" +
+		"```bash\n" +
+		"moved {\n" +
+		"```\n" +
+		"\n" +
+		"1. Step:\n" +
+		"   ~~~~tf\n" +
+		"   import {\n" +
+		"     to = aws_instance.main\n" +
+		"     id = \"i-123\"\n" +
+		"   }\n" +
+		"   ~~~~\n"
+
+	output, counts, err := removeBlocks([]byte(input), "README.md", allowedBlockTypes, false)
+	if err != nil {
+		t.Fatalf("removeBlocks failed: %v", err)
+	}
+	if counts["moved"] != 1 || counts["import"] != 1 {
+		t.Fatalf("unexpected counts: %#v", counts)
+	}
+
+	expected := "# Upgrade guide\n" +
+		"\n" +
+		"```hcl\n" +
+		"module \"vpc\" {\n" +
+		"  source = \"./vpc\"\n" +
+		"}\n" +
+		"\n" +
+		"```\n" +
+		"\n" +
+		"```terraform\n" +
+		"import {\n" +
+		"  to = ...\n" +
+		"}\n" +
+		"```\n" +
+		"\n" +
+		"```bash\n" +
+		"moved {\n" +
+		"```\n" +
+		"\n" +
+		"1. Step:\n" +
+		"   ~~~~tf\n" +
+		"   ~~~~\n"
+	if string(output) != expected {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", expected, string(output))
+	}
+}
+
+func TestFindMarkdownFencesUnclosed(t *testing.T) {
+	t.Parallel()
+
+	input := "```hcl\nmoved {}\n````\n```\nstill inside\n"
+	if fences := findMarkdownFences([]byte(input)); len(fences) != 1 {
+		t.Fatalf("expected the longer closing fence to close the block, got %d fences", len(fences))
+	}
+
+	input = "```hcl\nmoved {\n  from = a.b\n  to = a.c\n}\n"
+	if fences := findMarkdownFences([]byte(input)); len(fences) != 0 {
+		t.Fatalf("unclosed fence must be ignored, got %d fences", len(fences))
+	}
+}
+
+func TestIntegrationRunMarkdownOptIn(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "README.md")
+	input := "```hcl\nmoved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n```\n"
+	mustWriteFile(t, file, input, 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Files processed: 0") {
+		t.Fatalf("Markdown must not be processed without --markdown:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"--markdown", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "```hcl\n```\n" {
+		t.Fatalf("unexpected content: %q", string(content))
+	}
+}
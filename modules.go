@@ -0,0 +1,367 @@
+package tftidy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	hcljson "github.com/hashicorp/hcl/v2/json"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type moduleKind int
+
+const (
+	moduleUnknown moduleKind = iota
+	moduleRoot
+	moduleChild
+)
+
+func (k moduleKind) String() string {
+	switch k {
+	case moduleRoot:
+		return "root"
+	case moduleChild:
+		return "child"
+	default:
+		return "unknown"
+	}
+}
+
+// moduleInfo describes one module directory found during discovery.
+type moduleInfo struct {
+	dir  string
+	kind moduleKind
+	// hasRootConfig is set when the module configures a backend, Terraform
+	// Cloud, or providers.
+	hasRootConfig bool
+	// sources are the directories of local module calls, e.g. "./modules/vpc".
+	sources []string
+}
+
+// classifyModules inspects every module directory that contains one of files.
+// A module is a child when another module calls it with a local source or it
+// lives under one of childPaths; otherwise it is a root when it configures a
+// backend, Terraform Cloud, or providers. Files that fail to parse are
+// skipped here and reported by the caller when they are processed. The result
+// is keyed by moduleKey, so a directory is found however its path is spelled;
+// use moduleOf to look up a file's module.
+func classifyModules(files []string, childPaths []string) map[string]*moduleInfo {
+	dirs := make(map[string]string)
+	byKey := make(map[string][]string)
+	for _, moduleFiles := range groupFilesByDir(files) {
+		dir := filepath.Dir(moduleFiles[0])
+		key := moduleKey(dir)
+		if _, ok := dirs[key]; !ok {
+			dirs[key] = dir
+		}
+		byKey[key] = append(byKey[key], moduleFiles...)
+	}
+
+	modules := make(map[string]*moduleInfo, len(byKey))
+	for key, moduleFiles := range byKey {
+		modules[key] = readModuleInfo(dirs[key], moduleFiles)
+	}
+
+	called := make(map[string]struct{})
+	for _, info := range modules {
+		for _, source := range info.sources {
+			called[moduleKey(source)] = struct{}{}
+		}
+	}
+
+	for key, info := range modules {
+		_, isCalled := called[key]
+		switch {
+		case isCalled || isUnderAny(info.dir, childPaths):
+			info.kind = moduleChild
+		case info.hasRootConfig:
+			info.kind = moduleRoot
+		}
+	}
+
+	return modules
+}
+
+// moduleOf returns the module of path in modules, or nil when its directory
+// was not classified.
+func moduleOf(modules map[string]*moduleInfo, path string) *moduleInfo {
+	return modules[moduleKey(filepath.Dir(path))]
+}
+
+// moduleKey identifies the module in dir by its absolute, symlink-resolved
+// path, falling back to the absolute path when it cannot be resolved.
+func moduleKey(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return filepath.Clean(dir)
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	return abs
+}
+
+// readModuleInfo parses files, which all belong to the module in dir.
+func readModuleInfo(dir string, files []string) *moduleInfo {
+	info := &moduleInfo{dir: dir, kind: moduleUnknown}
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if isJSONFile(path) {
+			hasRoot, sources, err := jsonModuleInfo(content, path, dir)
+			if err != nil {
+				continue
+			}
+			info.hasRootConfig = info.hasRootConfig || hasRoot
+			info.sources = append(info.sources, sources...)
+			continue
+		}
+		body, err := parseModuleBody(content, path)
+		if err != nil {
+			continue
+		}
+		if hasRootConfig(body) {
+			info.hasRootConfig = true
+		}
+		info.sources = append(info.sources, localModuleSources(body, dir)...)
+	}
+	return info
+}
+
+// reachableModules follows local module calls from roots and returns the
+// absolute directories of every module reached, including the roots.
+func reachableModules(roots []string) (map[string]struct{}, error) {
+	reached := make(map[string]struct{})
+	queue := make([]string, 0, len(roots))
+	for _, root := range roots {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("root module %s is not a directory", root)
+		}
+		queue = append(queue, root)
+	}
+
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := reached[absDir]; ok {
+			continue
+		}
+		reached[absDir] = struct{}{}
+
+		files, err := listModuleFiles(dir)
+		if err != nil {
+			// A missing local module is Terraform's error to report, not ours.
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		queue = append(queue, readModuleInfo(dir, files).sources...)
+	}
+
+	return reached, nil
+}
+
+// filterReachableFiles keeps the files whose module is reachable from roots
+// and returns the directories of the remaining modules separately.
+func filterReachableFiles(files []string, roots []string) ([]string, []string, error) {
+	reached, err := reachableModules(roots)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kept := make([]string, 0, len(files))
+	unreachable := make([]string, 0)
+	for _, moduleFiles := range groupFilesByDir(files) {
+		dir := filepath.Dir(moduleFiles[0])
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := reached[absDir]; ok {
+			kept = append(kept, moduleFiles...)
+			continue
+		}
+		unreachable = append(unreachable, dir)
+	}
+
+	sort.Strings(kept)
+	return kept, unreachable, nil
+}
+
+// listModuleFiles returns the Terraform files directly inside dir, sorted.
+func listModuleFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !isTerraformFile(entry.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	return files, nil
+}
+
+func parseModuleBody(content []byte, filename string) (*hclsyntax.Body, error) {
+	syntaxFile, diags := hclsyntax.ParseConfig(content, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	body, ok := syntaxFile.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, hcl.Diagnostics{{Severity: hcl.DiagError, Summary: "unexpected HCL body type"}}
+	}
+
+	return body, nil
+}
+
+func hasRootConfig(body *hclsyntax.Body) bool {
+	for _, block := range body.Blocks {
+		switch block.Type {
+		case "provider":
+			return true
+		case "terraform":
+			for _, nested := range block.Body.Blocks {
+				if nested.Type == "backend" || nested.Type == "cloud" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// localModuleSources returns the cleaned directories of module calls in body
+// whose source is a local path relative to dir.
+func localModuleSources(body *hclsyntax.Body, dir string) []string {
+	sources := make([]string, 0)
+	for _, block := range body.Blocks {
+		if block.Type != "module" {
+			continue
+		}
+		attr, ok := block.Body.Attributes["source"]
+		if !ok {
+			continue
+		}
+		if source, ok := localModuleSource(attr.Expr, dir); ok {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// localModuleSource returns the directory a module source expression points
+// to when it is a local path relative to dir, e.g. "./modules/vpc".
+func localModuleSource(expr hcl.Expression, dir string) (string, bool) {
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() || !value.Type().Equals(cty.String) || value.IsNull() {
+		return "", false
+	}
+	source := value.AsString()
+	if !strings.HasPrefix(source, "./") && !strings.HasPrefix(source, "../") {
+		return "", false
+	}
+	return filepath.Join(dir, filepath.FromSlash(source)), true
+}
+
+var (
+	jsonModuleSchema = &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "module", LabelNames: []string{"name"}},
+			{Type: "provider", LabelNames: []string{"name"}},
+			{Type: "terraform"},
+		},
+	}
+	jsonModuleCallSchema = &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{{Name: "source"}},
+	}
+	jsonTerraformSchema = &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "backend", LabelNames: []string{"type"}},
+			{Type: "cloud"},
+		},
+	}
+)
+
+// jsonModuleInfo is hasRootConfig and localModuleSources for a .tf.json file,
+// which has no hclsyntax body and is decoded through a schema instead.
+func jsonModuleInfo(content []byte, filename, dir string) (bool, []string, error) {
+	file, diags := hcljson.Parse(content, filename)
+	if diags.HasErrors() {
+		return false, nil, diags
+	}
+	body, _, diags := file.Body.PartialContent(jsonModuleSchema)
+	if diags.HasErrors() {
+		return false, nil, diags
+	}
+
+	hasRoot := false
+	sources := make([]string, 0)
+	for _, block := range body.Blocks {
+		switch block.Type {
+		case "provider":
+			hasRoot = true
+		case "terraform":
+			nested, _, diags := block.Body.PartialContent(jsonTerraformSchema)
+			if !diags.HasErrors() && len(nested.Blocks) > 0 {
+				hasRoot = true
+			}
+		case "module":
+			call, _, diags := block.Body.PartialContent(jsonModuleCallSchema)
+			if diags.HasErrors() {
+				continue
+			}
+			attr, ok := call.Attributes["source"]
+			if !ok {
+				continue
+			}
+			if source, ok := localModuleSource(attr.Expr, dir); ok {
+				sources = append(sources, source)
+			}
+		}
+	}
+	return hasRoot, sources, nil
+}
+
+// isUnderAny reports whether dir is one of parents or is nested below one.
+func isUnderAny(dir string, parents []string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	for _, parent := range parents {
+		absParent, err := filepath.Abs(parent)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(absParent, absDir)
+		if err != nil {
+			continue
+		}
+		if rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+			return true
+		}
+	}
+	return false
+}
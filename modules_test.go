@@ -0,0 +1,248 @@
+package tftidy
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassifyModules(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	mustMkdirAll(t, filepath.Join(tempDir, "live"))
+	mustMkdirAll(t, filepath.Join(tempDir, "modules", "vpc"))
+	mustMkdirAll(t, filepath.Join(tempDir, "published", "dns"))
+	mustMkdirAll(t, filepath.Join(tempDir, "scratch"))
+
+	mustWriteFile(t, filepath.Join(tempDir, "live", "main.tf"), `terraform {
+  backend "s3" {}
+}
+
+module "vpc" {
+  source = "../modules/vpc"
+}
+
+module "remote" {
+  source = "terraform-aws-modules/vpc/aws"
+}
+`, 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "modules", "vpc", "main.tf"), "provider \"aws\" {}\n", 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "published", "dns", "main.tf"), "resource \"null_resource\" \"x\" {}\n", 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "scratch", "main.tf"), "resource \"null_resource\" \"x\" {}\n", 0o644)
+
+	files, err := discoverFiles(tempDir)
+	if err != nil {
+		t.Fatalf("discoverFiles failed: %v", err)
+	}
+
+	modules := classifyModules(files, []string{filepath.Join(tempDir, "published")})
+
+	expected := map[string]moduleKind{
+		filepath.Join(tempDir, "live"):             moduleRoot,
+		filepath.Join(tempDir, "modules", "vpc"):   moduleChild,
+		filepath.Join(tempDir, "published", "dns"): moduleChild,
+		filepath.Join(tempDir, "scratch"):          moduleUnknown,
+	}
+	for dir, want := range expected {
+		info, ok := modules[moduleKey(dir)]
+		if !ok {
+			t.Fatalf("module %s was not classified", dir)
+		}
+		if info.kind != want {
+			t.Fatalf("unexpected kind for %s: expected %s got %s", dir, want, info.kind)
+		}
+	}
+}
+
+func TestIntegrationRunProtectChildModules(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	mustMkdirAll(t, filepath.Join(tempDir, "modules", "vpc"))
+	mustMkdirAll(t, filepath.Join(tempDir, "modules", "dns"))
+
+	moved := `moved {
+  from = aws_instance.old
+  to   = aws_instance.main
+}
+`
+	rootFile := filepath.Join(tempDir, "main.tf")
+	vpcFile := filepath.Join(tempDir, "modules", "vpc", "main.tf")
+	dnsFile := filepath.Join(tempDir, "modules", "dns", "main.tf")
+	mustWriteFile(t, rootFile, `module "vpc" {
+  source = "./modules/vpc"
+}
+
+module "dns" {
+  source = "./modules/dns"
+}
+
+`+moved, 0o644)
+	mustWriteFile(t, vpcFile, moved, 0o644)
+	mustWriteFile(t, dnsFile, moved, 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--protect-child-modules", "--allow-moved-in", filepath.Join(tempDir, "modules", "dns"), tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+
+	for path, wantMoved := range map[string]bool{rootFile: false, vpcFile: true, dnsFile: false} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		if got := containsBlockDeclaration(string(content), "moved"); got != wantMoved {
+			t.Fatalf("unexpected moved block presence in %s: expected %v\n%s", path, wantMoved, string(content))
+		}
+	}
+}
+
+func TestIntegrationRunProtectChildModulesScannedAlone(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	moved := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+
+	tests := []struct {
+		name        string
+		git         bool
+		target      string
+		wantMoved   bool
+		wantWarning bool
+	}{
+		{name: "directory in repository", git: true, target: "child", wantMoved: true},
+		{name: "file in repository", git: true, target: filepath.Join("child", "main.tf"), wantMoved: true},
+		{name: "outside a repository", target: "child", wantMoved: false, wantWarning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			mustMkdirAll(t, filepath.Join(tempDir, "child"))
+			childFile := filepath.Join(tempDir, "child", "main.tf")
+			mustWriteFile(t, filepath.Join(tempDir, "main.tf"), "module \"child\" {\n  source = \"./child\"\n}\n", 0o644)
+			mustWriteFile(t, childFile, moved, 0o644)
+			if tt.git {
+				mustGit(t, tempDir, "init", "-q")
+			}
+
+			var stdout bytes.Buffer
+			var stderr bytes.Buffer
+			code := run([]string{"--protect-child-modules", filepath.Join(tempDir, tt.target)}, &stdout, &stderr)
+			if code != 0 {
+				t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+			}
+			if got := strings.Contains(stderr.String(), "Warning: not in a git repository"); got != tt.wantWarning {
+				t.Fatalf("warning = %v, want %v: %s", got, tt.wantWarning, stderr.String())
+			}
+
+			content, err := os.ReadFile(childFile)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if got := containsBlockDeclaration(string(content), "moved"); got != tt.wantMoved {
+				t.Fatalf("moved block kept = %v, want %v:\n%s", got, tt.wantMoved, string(content))
+			}
+		})
+	}
+}
+
+func TestIntegrationRunRoots(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	mustMkdirAll(t, filepath.Join(tempDir, "live"))
+	mustMkdirAll(t, filepath.Join(tempDir, "modules", "app"))
+	mustMkdirAll(t, filepath.Join(tempDir, "modules", "db"))
+	mustMkdirAll(t, filepath.Join(tempDir, "examples", "basic"))
+
+	moved := `moved {
+  from = aws_instance.old
+  to   = aws_instance.main
+}
+`
+	liveFile := filepath.Join(tempDir, "live", "main.tf")
+	appFile := filepath.Join(tempDir, "modules", "app", "main.tf")
+	dbFile := filepath.Join(tempDir, "modules", "db", "main.tf")
+	exampleFile := filepath.Join(tempDir, "examples", "basic", "main.tf")
+	mustWriteFile(t, liveFile, "module \"app\" {\n  source = \"../modules/app\"\n}\n\n"+moved, 0o644)
+	mustWriteFile(t, appFile, "module \"db\" {\n  source = \"../db\"\n}\n\n"+moved, 0o644)
+	mustWriteFile(t, dbFile, moved, 0o644)
+	mustWriteFile(t, exampleFile, moved, 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--roots", filepath.Join(tempDir, "live"), tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+
+	for path, wantMoved := range map[string]bool{liveFile: false, appFile: false, dbFile: false, exampleFile: true} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		if got := containsBlockDeclaration(string(content), "moved"); got != wantMoved {
+			t.Fatalf("unexpected moved block presence in %s: expected %v\n%s", path, wantMoved, string(content))
+		}
+	}
+
+	expected := "Unreachable modules:\n  " + filepath.Join(tempDir, "examples", "basic") + "\n"
+	if !strings.Contains(stdout.String(), expected) {
+		t.Fatalf("unreachable modules should be reported:\n%s", stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "Files processed: 3") {
+		t.Fatalf("only reachable files should be processed:\n%s", stdout.String())
+	}
+}
+
+func TestIntegrationRunRootsJSONModuleCall(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	mustMkdirAll(t, filepath.Join(tempDir, "live"))
+	mustMkdirAll(t, filepath.Join(tempDir, "modules", "app"))
+	mustMkdirAll(t, filepath.Join(tempDir, "examples", "basic"))
+
+	moved := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+	appFile := filepath.Join(tempDir, "modules", "app", "main.tf")
+	exampleFile := filepath.Join(tempDir, "examples", "basic", "main.tf")
+	mustWriteFile(t, filepath.Join(tempDir, "live", "main.tf.json"), `{
+  "module": {
+    "app": {
+      "source": "../modules/app"
+    }
+  }
+}
+`, 0o644)
+	mustWriteFile(t, appFile, moved, 0o644)
+	mustWriteFile(t, exampleFile, moved, 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--roots", filepath.Join(tempDir, "live"), tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+
+	for path, wantMoved := range map[string]bool{appFile: false, exampleFile: true} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		if got := containsBlockDeclaration(string(content), "moved"); got != wantMoved {
+			t.Fatalf("unexpected moved block presence in %s: expected %v\n%s", path, wantMoved, string(content))
+		}
+	}
+}
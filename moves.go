@@ -0,0 +1,309 @@
+package tftidy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/spf13/pflag"
+)
+
+// movedBlock is a top-level moved block together with the file it lives in.
+type movedBlock struct {
+	path  string
+	file  *hclwrite.File
+	block *hclwrite.Block
+	from  string
+	to    string
+}
+
+// moveChain is a sequence of moved blocks where each block's to address is
+// the next block's from address.
+type moveChain []*movedBlock
+
+func (c moveChain) addresses() []string {
+	addrs := make([]string, 0, len(c)+1)
+	for _, mb := range c {
+		addrs = append(addrs, mb.from)
+	}
+	return append(addrs, c[len(c)-1].to)
+}
+
+func runCollapseMoves(args []string, stdout, stderr io.Writer) int {
+	fs := pflag.NewFlagSet("tftidy collapse-moves", pflag.ContinueOnError)
+	fs.SortFlags = false
+	fs.SetOutput(stderr)
+
+	dryRun := fs.BoolP("dry-run", "n", false, "Preview changes without modifying files")
+	verbose := fs.BoolP("verbose", "v", false, "Show each collapsed chain")
+	discovery := addDiscoveryFlags(fs)
+	showHelp := fs.BoolP("help", "h", false, "Show help")
+
+	if err := fs.Parse(args); err != nil {
+		writef(stderr, "Error: %v\n\n", err)
+		printCollapseMovesUsage(stderr)
+		return 2
+	}
+
+	if *showHelp {
+		printCollapseMovesUsage(stdout)
+		return 0
+	}
+
+	remaining := fs.Args()
+	if len(remaining) > 1 {
+		writef(stderr, "Error: expected at most one directory argument\n\n")
+		printCollapseMovesUsage(stderr)
+		return 2
+	}
+
+	dir := "."
+	if len(remaining) == 1 {
+		dir = remaining[0]
+	}
+
+	discover, err := discovery.options()
+	if err != nil {
+		writef(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if *verbose {
+		discover.skipped = func(path, reason string) {
+			writef(stdout, "Skipping: %s (%s)\n", path, reason)
+		}
+	}
+	files, err := walkFiles(dir, discover, isTerraformFile)
+	if err != nil {
+		writef(stderr, "Error: failed to discover Terraform files: %v\n", err)
+		return 1
+	}
+	files = nativeSyntaxFiles(files)
+
+	errored := 0
+	chainsCollapsed := 0
+	blocksRewritten := 0
+	for _, moduleFiles := range groupFilesByDir(files) {
+		parsed := make(map[string]*hclwrite.File, len(moduleFiles))
+		blocks := make([]*movedBlock, 0)
+		failed := false
+		for _, path := range moduleFiles {
+			file, moved, err := parseMovedBlocks(path)
+			if err != nil {
+				errored++
+				failed = true
+				writef(stderr, "Error processing %s: %v\n", path, err)
+				continue
+			}
+			parsed[path] = file
+			blocks = append(blocks, moved...)
+		}
+		// Chains may span files, so a module with an unreadable file is
+		// left untouched, like one with a cycle.
+		if failed {
+			continue
+		}
+
+		chains, err := buildMoveChains(blocks)
+		if err != nil {
+			errored++
+			writef(stderr, "Error in module %s: %v\n", filepath.Dir(moduleFiles[0]), err)
+			continue
+		}
+
+		changed := make(map[string]struct{})
+		for _, chain := range chains {
+			if len(chain) < 2 {
+				continue
+			}
+			addresses := chain.addresses()
+			rewritten := collapseMoveChain(chain)
+			if len(rewritten) == 0 {
+				continue
+			}
+			if *verbose {
+				writef(stdout, "Collapsing: %s\n", strings.Join(addresses, " -> "))
+			}
+			for _, mb := range rewritten {
+				changed[mb.path] = struct{}{}
+			}
+			chainsCollapsed++
+			blocksRewritten += len(rewritten)
+		}
+
+		if *dryRun {
+			continue
+		}
+
+		for _, path := range moduleFiles {
+			if _, ok := changed[path]; !ok {
+				continue
+			}
+			updated := bytes.TrimLeft(hclwrite.Format(parsed[path].Bytes()), "\n")
+			if err := writeFileAtomic(path, updated, false); err != nil {
+				errored++
+				writef(stderr, "Error processing %s: %v\n", path, err)
+			}
+		}
+	}
+
+	writef(stdout, "Chains collapsed: %d\n", chainsCollapsed)
+	writef(stdout, "Blocks rewritten: %d\n", blocksRewritten)
+
+	if errored > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// parseMovedBlocks parses path and returns its top-level moved blocks in
+// source order.
+func parseMovedBlocks(path string) (*hclwrite.File, []*movedBlock, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, diags := hclwrite.ParseConfig(content, path, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, nil, fmt.Errorf("failed to parse %s: %s", path, diags.Error())
+	}
+
+	blocks := make([]*movedBlock, 0)
+	for _, block := range file.Body().Blocks() {
+		if block.Type() != "moved" {
+			continue
+		}
+		from := attributeText(block.Body(), "from")
+		to := attributeText(block.Body(), "to")
+		if from == "" || to == "" {
+			continue
+		}
+		blocks = append(blocks, &movedBlock{path: path, file: file, block: block, from: from, to: to})
+	}
+
+	return file, blocks, nil
+}
+
+// buildMoveChains links moved blocks into chains by matching each block's to
+// address with another block's from address. Several addresses may be moved
+// to the same address, as collapsed chains are; each of them starts its own
+// chain. Ambiguous statements and cycles are reported as errors because
+// Terraform rejects them as well.
+func buildMoveChains(blocks []*movedBlock) ([]moveChain, error) {
+	byFrom := make(map[string]*movedBlock, len(blocks))
+	targets := make(map[string]struct{}, len(blocks))
+	for _, mb := range blocks {
+		if other, ok := byFrom[mb.from]; ok {
+			return nil, fmt.Errorf("ambiguous moved blocks: %s is moved to both %s and %s", mb.from, other.to, mb.to)
+		}
+		byFrom[mb.from] = mb
+		targets[mb.to] = struct{}{}
+	}
+
+	visited := make(map[*movedBlock]struct{}, len(blocks))
+	chains := make([]moveChain, 0)
+	for _, mb := range blocks {
+		if _, ok := targets[mb.from]; ok {
+			continue
+		}
+		chain := moveChain{}
+		inChain := make(map[*movedBlock]struct{})
+		for next := mb; next != nil; next = byFrom[next.to] {
+			if _, ok := inChain[next]; ok {
+				return nil, cycleError(next, byFrom)
+			}
+			inChain[next] = struct{}{}
+			visited[next] = struct{}{}
+			chain = append(chain, next)
+		}
+		chains = append(chains, chain)
+	}
+
+	for _, mb := range blocks {
+		if _, ok := visited[mb]; ok {
+			continue
+		}
+		return nil, cycleError(mb, byFrom)
+	}
+
+	return chains, nil
+}
+
+// cycleError reports the cycle of moved blocks that mb is part of.
+func cycleError(mb *movedBlock, byFrom map[string]*movedBlock) error {
+	cycle := []string{mb.from}
+	for next := byFrom[mb.to]; next != mb; next = byFrom[next.to] {
+		cycle = append(cycle, next.from)
+	}
+	return fmt.Errorf("cycle in moved blocks: %s -> %s", strings.Join(cycle, " -> "), mb.from)
+}
+
+// collapseMoveChain rewrites the to address of every hop of chain but the
+// last to the chain's final address, so state left at any intermediate
+// address still moves there in one step. The final hop is kept as it is. It
+// returns the hops that were rewritten.
+func collapseMoveChain(chain moveChain) []*movedBlock {
+	last := chain[len(chain)-1]
+	toTokens := last.block.Body().GetAttribute("to").Expr().BuildTokens(nil)
+
+	rewritten := make([]*movedBlock, 0, len(chain)-1)
+	for _, mb := range chain[:len(chain)-1] {
+		if mb.to == last.to {
+			continue
+		}
+		mb.block.Body().SetAttributeRaw("to", toTokens)
+		mb.to = last.to
+		rewritten = append(rewritten, mb)
+	}
+
+	return rewritten
+}
+
+// groupFilesByDir groups files by their parent directory, which is how
+// Terraform scopes a module. Groups are returned in directory order.
+func groupFilesByDir(files []string) [][]string {
+	byDir := make(map[string][]string)
+	dirs := make([]string, 0)
+	for _, path := range files {
+		dir := filepath.Dir(path)
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], path)
+	}
+
+	sort.Strings(dirs)
+	groups := make([][]string, 0, len(dirs))
+	for _, dir := range dirs {
+		groups = append(groups, byDir[dir])
+	}
+
+	return groups
+}
+
+func attributeText(body *hclwrite.Body, name string) string {
+	attr := body.GetAttribute(name)
+	if attr == nil {
+		return ""
+	}
+	return strings.TrimSpace(string(attr.Expr().BuildTokens(nil).Bytes()))
+}
+
+func printCollapseMovesUsage(w io.Writer) {
+	writeln(w, "tftidy collapse-moves - Collapse chained moved blocks into direct moves")
+	writeln(w)
+	writeln(w, "Usage: tftidy collapse-moves [options] [directory]")
+	writeln(w)
+	writeln(w, "Options:")
+	writeln(w, "  -n, --dry-run                  Preview changes without modifying files")
+	writeln(w, "  -v, --verbose                  Show each collapsed chain")
+	printDiscoveryUsage(w)
+	writeln(w, "  -h, --help                     Show help")
+}
@@ -0,0 +1,251 @@
+package tftidy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildMoveChains(t *testing.T) {
+	t.Parallel()
+
+	blocks := []*movedBlock{
+		{from: "aws_instance.b", to: "aws_instance.c"},
+		{from: "aws_instance.a", to: "aws_instance.b"},
+		{from: "aws_instance.x", to: "aws_instance.y"},
+	}
+
+	chains, err := buildMoveChains(blocks)
+	if err != nil {
+		t.Fatalf("buildMoveChains failed: %v", err)
+	}
+	if len(chains) != 2 {
+		t.Fatalf("expected 2 chains, got %d", len(chains))
+	}
+
+	got := strings.Join(chains[0].addresses(), " -> ")
+	if got != "aws_instance.a -> aws_instance.b -> aws_instance.c" {
+		t.Fatalf("unexpected first chain: %s", got)
+	}
+	if len(chains[1]) != 1 {
+		t.Fatalf("expected single-hop second chain, got %d hops", len(chains[1]))
+	}
+}
+
+func TestBuildMoveChainsSharedTarget(t *testing.T) {
+	t.Parallel()
+
+	blocks := []*movedBlock{
+		{from: "aws_instance.a", to: "aws_instance.c"},
+		{from: "aws_instance.b", to: "aws_instance.c"},
+		{from: "aws_instance.c", to: "aws_instance.d"},
+	}
+
+	chains, err := buildMoveChains(blocks)
+	if err != nil {
+		t.Fatalf("buildMoveChains failed: %v", err)
+	}
+	got := make([]string, 0, len(chains))
+	for _, chain := range chains {
+		got = append(got, strings.Join(chain.addresses(), " -> "))
+	}
+	want := []string{
+		"aws_instance.a -> aws_instance.c -> aws_instance.d",
+		"aws_instance.b -> aws_instance.c -> aws_instance.d",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected chains: %q", got)
+	}
+}
+
+func TestBuildMoveChainsErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		blocks []*movedBlock
+		want   string
+	}{
+		{
+			name: "cycle",
+			blocks: []*movedBlock{
+				{from: "aws_instance.a", to: "aws_instance.b"},
+				{from: "aws_instance.b", to: "aws_instance.a"},
+			},
+			want: "cycle in moved blocks: aws_instance.a -> aws_instance.b -> aws_instance.a",
+		},
+		{
+			name: "duplicate from",
+			blocks: []*movedBlock{
+				{from: "aws_instance.a", to: "aws_instance.b"},
+				{from: "aws_instance.a", to: "aws_instance.c"},
+			},
+			want: "ambiguous moved blocks",
+		},
+		{
+			name: "cycle after a chain",
+			blocks: []*movedBlock{
+				{from: "aws_instance.a", to: "aws_instance.b"},
+				{from: "aws_instance.b", to: "aws_instance.c"},
+				{from: "aws_instance.c", to: "aws_instance.b"},
+			},
+			want: "cycle in moved blocks: aws_instance.b -> aws_instance.c -> aws_instance.b",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := buildMoveChains(tc.blocks)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestRunCollapseMovesAcrossFiles(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	first := filepath.Join(tempDir, "a.tf")
+	second := filepath.Join(tempDir, "b.tf")
+	mustWriteFile(t, first, `# first refactor
+moved {
+  from = aws_instance.a
+  to   = aws_instance.b
+}
+`, 0o644)
+	mustWriteFile(t, second, `resource "aws_instance" "c" {}
+
+# second refactor
+moved {
+  from = aws_instance.b
+  to   = aws_instance.c
+}
+`, 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"collapse-moves", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Chains collapsed: 1") || !strings.Contains(stdout.String(), "Blocks rewritten: 1") {
+		t.Fatalf("unexpected stdout: %s", stdout.String())
+	}
+
+	expected := map[string]string{
+		first: `# first refactor
+moved {
+  from = aws_instance.a
+  to   = aws_instance.c
+}
+`,
+		// The final hop is still needed for state at aws_instance.b.
+		second: `resource "aws_instance" "c" {}
+
+# second refactor
+moved {
+  from = aws_instance.b
+  to   = aws_instance.c
+}
+`,
+	}
+	for path, want := range expected {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read file: %v", err)
+		}
+		if string(content) != want {
+			t.Fatalf("unexpected content in %s\nexpected:\n%s\nactual:\n%s", path, want, string(content))
+		}
+	}
+
+	// Collapsed chains are left as they are.
+	stdout.Reset()
+	code = run([]string{"collapse-moves", tempDir}, &stdout, &stderr)
+	if code != 0 || !strings.Contains(stdout.String(), "Chains collapsed: 0") {
+		t.Fatalf("second run should be a no-op: code=%d stdout=%s stderr=%s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestRunCollapseMovesParseError(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "a.tf")
+	input := `moved {
+  from = aws_instance.a
+  to   = aws_instance.b
+}
+
+moved {
+  from = aws_instance.b
+  to   = aws_instance.c
+}
+`
+	mustWriteFile(t, file, input, 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "b.tf"), "moved {\n", 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"collapse-moves", tempDir}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "Chains collapsed: 0") {
+		t.Fatalf("unexpected stdout: %s", stdout.String())
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != input {
+		t.Fatalf("module with a file that fails to parse must not be modified:\n%s", string(content))
+	}
+}
+
+func TestRunCollapseMovesCycle(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "main.tf")
+	input := `moved {
+  from = aws_instance.a
+  to   = aws_instance.b
+}
+
+moved {
+  from = aws_instance.b
+  to   = aws_instance.a
+}
+`
+	mustWriteFile(t, file, input, 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"collapse-moves", tempDir}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "cycle in moved blocks") {
+		t.Fatalf("unexpected stderr: %s", stderr.String())
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != input {
+		t.Fatalf("file with a cycle must not be modified")
+	}
+}
@@ -0,0 +1,139 @@
+package tftidy
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// relocationTarget is the file of one module directory that relocated blocks
+// are appended to, held in memory until the run writes it.
+type relocationTarget struct {
+	path     string
+	created  bool
+	original []byte
+	file     *hclwrite.File
+	blocks   int
+}
+
+// relocation moves transient blocks into a file of the given name in each
+// module directory instead of deleting them.
+type relocation struct {
+	name    string
+	targets map[string]*relocationTarget
+}
+
+func newRelocation(name string) (*relocation, error) {
+	if name != filepath.Base(name) || !isTerraformFile(name) || isJSONFile(name) {
+		return nil, fmt.Errorf("relocation target must be a .tf or .tofu file name, got %q", name)
+	}
+	return &relocation{name: name, targets: make(map[string]*relocationTarget)}, nil
+}
+
+// isTarget reports whether path is the relocation target of its directory.
+func (r *relocation) isTarget(path string) bool {
+	return filepath.Base(path) == r.name
+}
+
+// take removes the blocks of blockTypes from content, together with their
+// leading comments, and appends them to the target file of the directory of
+// filename.
+func (r *relocation) take(content []byte, filename string, blockTypes []string) ([]byte, map[string]int, error) {
+	file, diags := hclwrite.ParseConfig(content, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, nil, fmt.Errorf("failed to parse %s: %s", filename, diags.Error())
+	}
+
+	typeSet := make(map[string]struct{}, len(blockTypes))
+	for _, blockType := range blockTypes {
+		typeSet[blockType] = struct{}{}
+	}
+
+	body := file.Body()
+	taken := make([]*hclwrite.Block, 0)
+	for _, block := range body.Blocks() {
+		if _, ok := typeSet[block.Type()]; ok {
+			taken = append(taken, block)
+		}
+	}
+	if len(taken) == 0 {
+		return content, map[string]int{}, nil
+	}
+
+	target, err := r.target(filepath.Dir(filename))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	counts := make(map[string]int, len(blockTypes))
+	targetBody := target.file.Body()
+	for _, block := range taken {
+		body.RemoveBlock(block)
+		if len(targetBody.Blocks()) > 0 || len(targetBody.Attributes()) > 0 {
+			targetBody.AppendNewline()
+		}
+		targetBody.AppendBlock(block)
+		target.blocks++
+		counts[block.Type()]++
+	}
+
+	return hclwrite.Format(file.Bytes()), counts, nil
+}
+
+// target returns the relocation target of dir, reading it on first use.
+func (r *relocation) target(dir string) (*relocationTarget, error) {
+	if target, ok := r.targets[dir]; ok {
+		return target, nil
+	}
+
+	path := filepath.Join(dir, r.name)
+	target := &relocationTarget{path: path}
+	content, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		target.created = true
+	case err != nil:
+		return nil, err
+	default:
+		target.original = content
+	}
+
+	file, diags := hclwrite.ParseConfig(target.original, path, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse %s: %s", path, diags.Error())
+	}
+	target.file = file
+
+	r.targets[dir] = target
+	return target, nil
+}
+
+// changes returns the new content of every target that received blocks, in
+// directory order.
+func (r *relocation) changes() []fileChange {
+	dirs := make([]string, 0, len(r.targets))
+	for dir, target := range r.targets {
+		if target.blocks > 0 {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+
+	changes := make([]fileChange, 0, len(dirs))
+	for _, dir := range dirs {
+		target := r.targets[dir]
+		changes = append(changes, fileChange{
+			path:     target.path,
+			created:  target.created,
+			original: target.original,
+			updated:  hclwrite.Format(target.file.Bytes()),
+		})
+	}
+	return changes
+}
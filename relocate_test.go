@@ -0,0 +1,162 @@
+package tftidy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewRelocationRejectsInvalidNames(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"moved.tf.json", "moves.txt", filepath.Join("sub", "moved.tf")} {
+		if _, err := newRelocation(name); err == nil {
+			t.Fatalf("expected error for %q", name)
+		}
+	}
+	if _, err := newRelocation("moved.tofu"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestIntegrationRunRelocateTo(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	module := filepath.Join(tempDir, "modules", "vpc")
+	mustMkdirAll(t, module)
+
+	mustWriteFile(t, filepath.Join(tempDir, "main.tf"), `resource "aws_instance" "main" {}
+
+# Renamed in v2.
+moved {
+  from = aws_instance.old
+  to   = aws_instance.main
+}
+`, 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "imports.tf"), `import {
+  to = aws_instance.main
+  id = "i-123"
+}
+`, 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "moved.tf"), `moved {
+  from = aws_instance.older
+  to   = aws_instance.old
+}
+`, 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "gen.tf.json"), `{"moved": {"from": "a.b", "to": "a.c"}}`+"\n", 0o644)
+	mustWriteFile(t, filepath.Join(module, "main.tf"), `moved {
+  from = aws_vpc.old
+  to   = aws_vpc.main
+}
+`, 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--relocate-to", "moved.tf", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Files modified: 5") {
+		t.Fatalf("unexpected stats:\n%s", stdout.String())
+	}
+
+	expected := map[string]string{
+		filepath.Join(tempDir, "main.tf"):    "resource \"aws_instance\" \"main\" {}\n\n",
+		filepath.Join(tempDir, "imports.tf"): "",
+		filepath.Join(tempDir, "moved.tf"): `moved {
+  from = aws_instance.older
+  to   = aws_instance.old
+}
+
+import {
+  to = aws_instance.main
+  id = "i-123"
+}
+
+# Renamed in v2.
+moved {
+  from = aws_instance.old
+  to   = aws_instance.main
+}
+`,
+		filepath.Join(tempDir, "gen.tf.json"): `{"moved": {"from": "a.b", "to": "a.c"}}` + "\n",
+		filepath.Join(module, "main.tf"):      "",
+		filepath.Join(module, "moved.tf"): `moved {
+  from = aws_vpc.old
+  to   = aws_vpc.main
+}
+`,
+	}
+	for path, want := range expected {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		if string(content) != want {
+			t.Fatalf("unexpected content in %s\nexpected:\n%s\nactual:\n%s", path, want, string(content))
+		}
+	}
+}
+
+func TestIntegrationRunRelocateToRemovesOrphanedHeaders(t *testing.T) {
+	t.Parallel()
+
+	input := `resource "aws_instance" "main" {}
+
+# --- Moved blocks ---
+
+moved {
+  from = aws_instance.old
+  to   = aws_instance.main
+}
+`
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantHeader bool
+	}{
+		{name: "keep comments", wantHeader: true},
+		{name: "remove comments", args: []string{"--remove-comments"}, wantHeader: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			mainFile := filepath.Join(tempDir, "main.tf")
+			mustWriteFile(t, mainFile, input, 0o644)
+
+			var stdout bytes.Buffer
+			var stderr bytes.Buffer
+			args := append(append([]string{"--relocate-to", "moved.tf"}, tt.args...), tempDir)
+			code := run(args, &stdout, &stderr)
+			if code != 0 {
+				t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+			}
+
+			content, err := os.ReadFile(mainFile)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if got := strings.Contains(string(content), "# --- Moved blocks ---"); got != tt.wantHeader {
+				t.Fatalf("header kept = %v, want %v:\n%s", got, tt.wantHeader, string(content))
+			}
+			if containsBlockDeclaration(string(content), "moved") {
+				t.Fatalf("moved block should be relocated:\n%s", string(content))
+			}
+
+			target, err := os.ReadFile(filepath.Join(tempDir, "moved.tf"))
+			if err != nil {
+				t.Fatalf("failed to read target: %v", err)
+			}
+			if !containsBlockDeclaration(string(target), "moved") {
+				t.Fatalf("moved block should be in the target:\n%s", string(target))
+			}
+		})
+	}
+}
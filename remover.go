@@ -16,15 +16,46 @@ type byteRange struct {
 }
 
 func removeBlocks(content []byte, filename string, blockTypes []string, removeComments bool) ([]byte, map[string]int, error) {
+	if isJSONFile(filename) {
+		return removeJSONBlocks(content, filename, blockTypes)
+	}
+	if isTerragruntFile(filename) {
+		return removeTerragruntBlocks(content, filename, blockTypes, removeComments)
+	}
+	if isMarkdownFile(filename) {
+		return removeMarkdownBlocks(content, filename, blockTypes, removeComments)
+	}
 	if removeComments {
 		return removeBlocksWithComments(content, filename, blockTypes)
 	}
 	return removeBlocksPreservingComments(content, filename, blockTypes)
 }
 
+// collectRemovedBlocks returns the blocks removeBlocks removes from content,
+// for every file kind it supports.
+func collectRemovedBlocks(content []byte, filename string, blockTypes []string) ([]*transientBlock, error) {
+	if isJSONFile(filename) {
+		return collectJSONBlocks(content, filename, blockTypes)
+	}
+	if isTerragruntFile(filename) {
+		return collectTerragruntBlocks(content, filename, blockTypes)
+	}
+	if isMarkdownFile(filename) {
+		return collectMarkdownBlocks(content, filename, blockTypes), nil
+	}
+	return collectTransientBlocks(content, filename, blockTypes)
+}
+
 // removeBlocksWithComments uses hclwrite.RemoveBlock which naturally removes
 // leading comments attached to the block (hclwrite stores them as child tokens).
+// Section-header comments that only head removed blocks are removed first.
 func removeBlocksWithComments(content []byte, filename string, blockTypes []string) ([]byte, map[string]int, error) {
+	original := content
+	content, err := removeOrphanedHeaders(content, filename, blockTypes)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	file, diags := hclwrite.ParseConfig(content, filename, hcl.Pos{Line: 1, Column: 1})
 	if diags.HasErrors() {
 		return nil, nil, fmt.Errorf("failed to parse %s: %s", filename, diags.Error())
@@ -46,7 +77,7 @@ func removeBlocksWithComments(content []byte, filename string, blockTypes []stri
 	}
 
 	if sumCounts(counts) == 0 {
-		return content, map[string]int{}, nil
+		return original, map[string]int{}, nil
 	}
 
 	return hclwrite.Format(file.Bytes()), counts, nil
@@ -55,38 +86,37 @@ func removeBlocksWithComments(content []byte, filename string, blockTypes []stri
 // removeBlocksPreservingComments uses hclsyntax to get precise byte ranges
 // that exclude leading comments, then removes blocks at the byte level.
 func removeBlocksPreservingComments(content []byte, filename string, blockTypes []string) ([]byte, map[string]int, error) {
-	syntaxFile, diags := hclsyntax.ParseConfig(content, filename, hcl.Pos{Line: 1, Column: 1})
-	if diags.HasErrors() {
-		return nil, nil, fmt.Errorf("failed to parse %s: %s", filename, diags.Error())
-	}
-
-	syntaxBody, ok := syntaxFile.Body.(*hclsyntax.Body)
-	if !ok {
-		return nil, nil, fmt.Errorf("unexpected HCL body type in %s", filename)
+	blocks, err := collectTransientBlocks(content, filename, blockTypes)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	typeSet := make(map[string]struct{}, len(blockTypes))
-	for _, blockType := range blockTypes {
-		typeSet[blockType] = struct{}{}
+	if len(blocks) == 0 {
+		return content, map[string]int{}, nil
 	}
 
 	counts := make(map[string]int, len(blockTypes))
-	ranges := make([]byteRange, 0)
-
-	for _, block := range syntaxBody.Blocks {
-		if _, ok := typeSet[block.Type]; !ok {
-			continue
-		}
-
-		r := block.Range()
-		ranges = append(ranges, byteRange{start: r.Start.Byte, end: r.End.Byte})
-		counts[block.Type]++
+	for _, b := range blocks {
+		counts[b.blockType]++
 	}
 
-	if len(ranges) == 0 {
-		return content, map[string]int{}, nil
+	return hclwrite.Format(removeByteRanges(content, blockByteRanges(blocks))), counts, nil
+}
+
+// blockByteRanges returns the byte ranges of blocks, which excludes their
+// leading comments.
+func blockByteRanges(blocks []*transientBlock) []byteRange {
+	ranges := make([]byteRange, 0, len(blocks))
+	for _, b := range blocks {
+		ranges = append(ranges, byteRange{start: b.rng.Start.Byte, end: b.rng.End.Byte})
 	}
+	return ranges
+}
 
+// removeByteRanges deletes the given ranges from content together with the
+// indentation before each range and the line break after it. Ranges must be
+// sorted and must not overlap.
+func removeByteRanges(content []byte, ranges []byteRange) []byte {
 	result := append([]byte(nil), content...)
 	for i := len(ranges) - 1; i >= 0; i-- {
 		r := ranges[i]
@@ -107,7 +137,69 @@ func removeBlocksPreservingComments(content []byte, filename string, blockTypes
 		result = append(result[:start], result[end:]...)
 	}
 
-	return hclwrite.Format(result), counts, nil
+	return result
+}
+
+// transientBlock is a top-level transient block located in a parsed file.
+type transientBlock struct {
+	path      string
+	blockType string
+	rng       hcl.Range
+	source    []byte
+	address   string
+}
+
+// position formats the block's location as path:line.
+func (b *transientBlock) position() string {
+	return fmt.Sprintf("%s:%d", b.path, b.rng.Start.Line)
+}
+
+// collectTransientBlocks returns the top-level blocks of the given types in
+// source order. The address is the block's from attribute for moved and
+// removed blocks and its to attribute for import blocks.
+func collectTransientBlocks(content []byte, filename string, blockTypes []string) ([]*transientBlock, error) {
+	syntaxFile, diags := hclsyntax.ParseConfig(content, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse %s: %s", filename, diags.Error())
+	}
+
+	syntaxBody, ok := syntaxFile.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("unexpected HCL body type in %s", filename)
+	}
+
+	typeSet := make(map[string]struct{}, len(blockTypes))
+	for _, blockType := range blockTypes {
+		typeSet[blockType] = struct{}{}
+	}
+
+	blocks := make([]*transientBlock, 0)
+	for _, block := range syntaxBody.Blocks {
+		if _, ok := typeSet[block.Type]; !ok {
+			continue
+		}
+
+		addressAttr := "from"
+		if block.Type == "import" {
+			addressAttr = "to"
+		}
+
+		address := ""
+		if attr, ok := block.Body.Attributes[addressAttr]; ok {
+			address = string(attr.Expr.Range().SliceBytes(content))
+		}
+
+		r := block.Range()
+		blocks = append(blocks, &transientBlock{
+			path:      filename,
+			blockType: block.Type,
+			rng:       r,
+			source:    r.SliceBytes(content),
+			address:   address,
+		})
+	}
+
+	return blocks, nil
 }
 
 func normalizeConsecutiveNewlines(content []byte) []byte {
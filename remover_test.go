@@ -359,6 +359,10 @@ moved {
   from = aws_instance.old
   to   = aws_instance.main
 }
+
+resource "aws_instance" "other" {
+  ami = "ami-123456"
+}
 `
 
 	output, _, err := removeBlocks([]byte(input), "main.tf", []string{"moved"}, true)
@@ -0,0 +1,330 @@
+package tftidy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/spf13/pflag"
+)
+
+// ledgerFilter selects ledger entries. Every non-empty criterion must match;
+// within a criterion any of the values may match.
+type ledgerFilter struct {
+	files     []string
+	types     []string
+	addresses []string
+	runIDs    []string
+}
+
+func (f ledgerFilter) empty() bool {
+	return len(f.files) == 0 && len(f.types) == 0 && len(f.addresses) == 0 && len(f.runIDs) == 0
+}
+
+func (f ledgerFilter) matches(entry ledgerEntry) bool {
+	return matchesAny(f.files, entry.File) &&
+		matchesAny(f.types, entry.Type) &&
+		matchesAny(f.addresses, entry.Address) &&
+		matchesAny(f.runIDs, entry.RunID)
+}
+
+// matchesAny reports whether value is one of values, or values is empty.
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func runRestore(args []string, stdout, stderr io.Writer) int {
+	fs := pflag.NewFlagSet("tftidy restore", pflag.ContinueOnError)
+	fs.SortFlags = false
+	fs.SetOutput(stderr)
+
+	ledgerPath := fs.String("ledger", defaultLedgerFile, "Ledger file to restore from")
+	files := fs.StringArray("file", nil, "Restore blocks removed from this file (repeatable)")
+	rawTypes := fs.StringP("type", "t", "", "Restore blocks of these types, comma-separated")
+	addresses := fs.StringArray("address", nil, "Restore blocks for this address (repeatable)")
+	runIDs := fs.StringArray("run", nil, "Restore blocks removed by this run id (repeatable)")
+	dryRun := fs.BoolP("dry-run", "n", false, "Preview changes without modifying files")
+	verbose := fs.BoolP("verbose", "v", false, "Show each file being restored")
+	showHelp := fs.BoolP("help", "h", false, "Show help")
+
+	if err := fs.Parse(args); err != nil {
+		writef(stderr, "Error: %v\n\n", err)
+		printRestoreUsage(stderr)
+		return 2
+	}
+
+	if *showHelp {
+		printRestoreUsage(stdout)
+		return 0
+	}
+
+	if fs.NArg() > 0 {
+		writef(stderr, "Error: unexpected argument %q\n\n", fs.Arg(0))
+		printRestoreUsage(stderr)
+		return 2
+	}
+
+	filter := ledgerFilter{addresses: *addresses, runIDs: *runIDs}
+	if *rawTypes != "" {
+		types, err := parseBlockTypes(*rawTypes)
+		if err != nil {
+			writef(stderr, "Error: %v\n", err)
+			return 2
+		}
+		filter.types = types
+	}
+	for _, path := range *files {
+		file, err := ledgerFilePath(path)
+		if err != nil {
+			writef(stderr, "Error: %v\n", err)
+			return 2
+		}
+		filter.files = append(filter.files, file)
+	}
+	if filter.empty() {
+		writef(stderr, "Error: select blocks with --file, --type, --address, or --run\n\n")
+		printRestoreUsage(stderr)
+		return 2
+	}
+
+	entries, err := readLedger(*ledgerPath)
+	if err != nil {
+		writef(stderr, "Error: failed to read ledger: %v\n", err)
+		return 1
+	}
+
+	byFile := make(map[string][]ledgerEntry)
+	for _, entry := range entries {
+		if filter.matches(entry) {
+			byFile[entry.File] = append(byFile[entry.File], entry)
+		}
+	}
+	if len(byFile) == 0 {
+		writef(stderr, "Error: no ledger entries match\n")
+		return 1
+	}
+
+	errored := 0
+	restored := 0
+	present := 0
+	skipped := 0
+	for _, file := range sortedFileKeys(byFile) {
+		path := filepath.FromSlash(file)
+		if *verbose {
+			writef(stdout, "Restoring: %s\n", path)
+		}
+
+		// Blocks cleaned from JSON, Terragrunt, and Markdown files are
+		// recorded for reference, but splicing them back into those formats
+		// is not supported.
+		if !isTerraformFile(path) || isJSONFile(path) {
+			skipped += len(byFile[file])
+			writef(stderr, "Warning: skipping %s: blocks can only be restored into native-syntax Terraform files\n", path)
+			continue
+		}
+
+		// A file deleted by --delete-empty is created again.
+		content, err := os.ReadFile(path)
+		missing := errors.Is(err, os.ErrNotExist)
+		if err != nil && !missing {
+			errored++
+			writef(stderr, "Error processing %s: %v\n", path, err)
+			continue
+		}
+
+		// Lines were recorded before any of the blocks were removed, so
+		// inserting from the top keeps the later lines meaningful.
+		fileEntries := byFile[file]
+		sort.SliceStable(fileEntries, func(i, j int) bool { return fileEntries[i].Line < fileEntries[j].Line })
+
+		updated := content
+		fileRestored := 0
+		for _, entry := range fileEntries {
+			exists, err := containsBlock(updated, path, entry.Type, []byte(entry.Source))
+			if err != nil {
+				errored++
+				writef(stderr, "Error processing %s: %v\n", path, err)
+				break
+			}
+			if exists {
+				present++
+				writef(stdout, "Already present: %s block for %s in %s\n", entry.Type, entry.Address, path)
+				continue
+			}
+
+			next, err := insertBlock(updated, path, []byte(entry.Source), entry.Line)
+			if err != nil {
+				errored++
+				writef(stderr, "Error processing %s: %v\n", path, err)
+				break
+			}
+			updated = next
+			fileRestored++
+			writef(stdout, "Restored: %s block for %s in %s\n", entry.Type, entry.Address, path)
+		}
+
+		if fileRestored == 0 || *dryRun {
+			restored += fileRestored
+			continue
+		}
+		if missing {
+			err = createFileAtomic(path, updated)
+		} else {
+			err = writeFileAtomic(path, updated, false)
+		}
+		if err != nil {
+			errored++
+			writef(stderr, "Error processing %s: %v\n", path, err)
+			continue
+		}
+		restored += fileRestored
+	}
+
+	writeln(stdout)
+	writef(stdout, "Blocks restored: %d\n", restored)
+	writef(stdout, "Blocks already present: %d\n", present)
+	writef(stdout, "Blocks skipped: %d\n", skipped)
+
+	if errored > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// containsBlock reports whether content already has a top-level block of
+// blockType whose formatted text equals the formatted source.
+func containsBlock(content []byte, filename, blockType string, source []byte) (bool, error) {
+	blocks, err := collectTransientBlocks(content, filename, []string{blockType})
+	if err != nil {
+		return false, err
+	}
+
+	want := hclwrite.Format(source)
+	for _, b := range blocks {
+		if bytes.Equal(hclwrite.Format(b.source), want) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// insertBlock inserts source into content before the first top-level item
+// that starts at or after line, keeping comment lines directly above that
+// item with it, or at the end of the file. The result is formatted with
+// hclwrite.
+func insertBlock(content []byte, filename string, source []byte, line int) ([]byte, error) {
+	syntaxFile, diags := hclsyntax.ParseConfig(content, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse %s: %s", filename, diags.Error())
+	}
+	syntaxBody, ok := syntaxFile.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("unexpected HCL body type in %s", filename)
+	}
+
+	starts := make([]hcl.Pos, 0, len(syntaxBody.Attributes)+len(syntaxBody.Blocks))
+	for _, attr := range syntaxBody.Attributes {
+		starts = append(starts, attr.SrcRange.Start)
+	}
+	for _, block := range syntaxBody.Blocks {
+		starts = append(starts, block.Range().Start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Byte < starts[j].Byte })
+
+	block := append(append([]byte(nil), bytes.TrimSpace(source)...), '\n')
+	var buf bytes.Buffer
+	insertAt := -1
+	for _, start := range starts {
+		if start.Line >= line {
+			insertAt = leadingCommentStart(content, lineStart(content, start.Byte))
+			break
+		}
+	}
+
+	rest := []byte(nil)
+	if insertAt < 0 {
+		insertAt = len(content)
+	} else {
+		rest = content[insertAt:]
+	}
+
+	// The blank lines left where the block was removed become one.
+	before := bytes.TrimRight(content[:insertAt], "\n")
+	buf.Write(before)
+	if len(before) > 0 {
+		buf.WriteString("\n\n")
+	}
+	buf.Write(block)
+	if len(rest) > 0 {
+		buf.WriteByte('\n')
+		buf.Write(rest)
+	}
+
+	result := hclwrite.Format(buf.Bytes())
+	if _, diags := hclsyntax.ParseConfig(result, filename, hcl.Pos{Line: 1, Column: 1}); diags.HasErrors() {
+		return nil, fmt.Errorf("restored block does not parse: %s", diags.Error())
+	}
+	return result, nil
+}
+
+// lineStart returns the offset of the start of the line containing offset.
+func lineStart(content []byte, offset int) int {
+	return bytes.LastIndexByte(content[:offset], '\n') + 1
+}
+
+// leadingCommentStart moves offset, the start of a line, up over the comment
+// lines directly above it.
+func leadingCommentStart(content []byte, offset int) int {
+	for offset > 0 {
+		prev := lineStart(content, offset-1)
+		line := strings.TrimSpace(string(content[prev:offset]))
+		if !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "//") {
+			break
+		}
+		offset = prev
+	}
+	return offset
+}
+
+// sortedFileKeys returns the keys of m in sorted order.
+func sortedFileKeys(m map[string][]ledgerEntry) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func printRestoreUsage(w io.Writer) {
+	writeln(w, "tftidy restore - Re-insert blocks recorded in the removal ledger")
+	writeln(w)
+	writeln(w, "Usage: tftidy restore [options]")
+	writeln(w)
+	writeln(w, "Options:")
+	writeln(w, "      --ledger file              Ledger file to restore from (default \".tftidy/ledger.jsonl\")")
+	writeln(w, "      --file path                Restore blocks removed from this file (repeatable)")
+	writeln(w, "  -t, --type string              Restore blocks of these types, comma-separated")
+	writeln(w, "      --address address          Restore blocks for this address (repeatable)")
+	writeln(w, "      --run id                   Restore blocks removed by this run id (repeatable)")
+	writeln(w, "  -n, --dry-run                  Preview changes without modifying files")
+	writeln(w, "  -v, --verbose                  Show each file being restored")
+	writeln(w, "  -h, --help                     Show help")
+}
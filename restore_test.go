@@ -0,0 +1,212 @@
+package tftidy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInsertBlock(t *testing.T) {
+	t.Parallel()
+
+	content := "resource \"a\" \"b\" {}\n\n# Outputs\noutput \"id\" {\n  value = a.b.id\n}\n"
+	source := "moved {\n  from = a.old\n  to   = a.b\n}"
+
+	tests := []struct {
+		name     string
+		line     int
+		expected string
+	}{
+		{
+			name:     "before the item at the recorded line, above its comments",
+			line:     3,
+			expected: "resource \"a\" \"b\" {}\n\nmoved {\n  from = a.old\n  to   = a.b\n}\n\n# Outputs\noutput \"id\" {\n  value = a.b.id\n}\n",
+		},
+		{
+			name:     "at the end when nothing follows the recorded line",
+			line:     20,
+			expected: "resource \"a\" \"b\" {}\n\n# Outputs\noutput \"id\" {\n  value = a.b.id\n}\n\nmoved {\n  from = a.old\n  to   = a.b\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			output, err := insertBlock([]byte(content), "main.tf", []byte(source), tt.line)
+			if err != nil {
+				t.Fatalf("insertBlock failed: %v", err)
+			}
+			if string(output) != tt.expected {
+				t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", tt.expected, string(output))
+			}
+		})
+	}
+}
+
+func TestRunRestoreRequiresSelector(t *testing.T) {
+	t.Parallel()
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"restore"}, &stdout, &stderr)
+	if code != 2 {
+		t.Fatalf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "select blocks with") {
+		t.Fatalf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+func TestIntegrationRunRestore(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	ledgerFile := filepath.Join(tempDir, ".tftidy", "ledger.jsonl")
+	file := filepath.Join(tempDir, "main.tf")
+	input := `resource "aws_instance" "main" {}
+
+moved {
+  from = aws_instance.old
+  to   = aws_instance.main
+}
+
+import {
+  to = aws_instance.main
+  id = "i-123"
+}
+
+output "id" {
+  value = aws_instance.main.id
+}
+`
+	mustWriteFile(t, file, input, 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--ledger=" + ledgerFile, tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"restore", "--ledger", ledgerFile, "--type", "moved"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Blocks restored: 1") {
+		t.Fatalf("unexpected output:\n%s", stdout.String())
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	expected := `resource "aws_instance" "main" {}
+
+moved {
+  from = aws_instance.old
+  to   = aws_instance.main
+}
+
+output "id" {
+  value = aws_instance.main.id
+}
+`
+	if string(content) != expected {
+		t.Fatalf("unexpected content\nexpected:\n%s\nactual:\n%s", expected, string(content))
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"restore", "--ledger", ledgerFile, "--address", "aws_instance.old", "--address", "aws_instance.main", "--dry-run"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Blocks restored: 1") || !strings.Contains(stdout.String(), "Blocks already present: 1") {
+		t.Fatalf("unexpected output:\n%s", stdout.String())
+	}
+	if after, _ := os.ReadFile(file); string(after) != expected {
+		t.Fatalf("dry-run must not modify files:\n%s", string(after))
+	}
+}
+
+func TestIntegrationRunRestoreDeletedFile(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	ledgerFile := filepath.Join(tempDir, ".tftidy", "ledger.jsonl")
+	file := filepath.Join(tempDir, "moved.tf")
+	input := `moved {
+  from = aws_instance.old
+  to   = aws_instance.main
+}
+`
+	mustWriteFile(t, file, input, 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--ledger=" + ledgerFile, "--delete-empty", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be deleted, got %v", file, err)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"restore", "--ledger", ledgerFile, "--type", "moved"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Blocks restored: 1") {
+		t.Fatalf("unexpected output:\n%s", stdout.String())
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("deleted file should be created again: %v", err)
+	}
+	if string(content) != input {
+		t.Fatalf("unexpected content\nexpected:\n%s\nactual:\n%s", input, string(content))
+	}
+}
+
+func TestIntegrationRunRestoreSkipsNonNativeFiles(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	ledgerFile := filepath.Join(tempDir, ".tftidy", "ledger.jsonl")
+	moved := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+	mustWriteFile(t, filepath.Join(tempDir, "main.tf"), "resource \"aws_instance\" \"main\" {}\n\n"+moved, 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "main.tf.json"), "{\n  \"moved\": [{\"from\": \"a.b\", \"to\": \"a.c\"}],\n  \"locals\": {}\n}\n", 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "README.md"), "# Notes\n\n```hcl\n"+moved+"```\n", 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--ledger", ledgerFile, "--markdown", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"restore", "--ledger", ledgerFile, "--type", "moved"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	for _, want := range []string{"Blocks restored: 1", "Blocks skipped: 2"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Fatalf("missing %q in output:\n%s", want, stdout.String())
+		}
+	}
+	for _, name := range []string{"main.tf.json", "README.md"} {
+		if !strings.Contains(stderr.String(), "Warning: skipping "+filepath.Join(tempDir, name)) {
+			t.Fatalf("missing warning for %s:\n%s", name, stderr.String())
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package tftidy
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/spf13/pflag"
@@ -16,6 +19,7 @@ var allowedBlockTypes = []string{"moved", "removed", "import"}
 type stats struct {
 	filesProcessed int
 	filesModified  int
+	filesDeleted   int
 	filesErrored   int
 	blockCounts    map[string]int
 }
@@ -25,6 +29,23 @@ func Run(args []string, stdout, stderr io.Writer) int {
 }
 
 func run(args []string, stdout, stderr io.Writer) int {
+	return runWithStdin(args, os.Stdin, stdout, stderr)
+}
+
+func runWithStdin(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "check":
+			return runCheck(args[1:], stdout, stderr)
+		case "collapse-moves":
+			return runCollapseMoves(args[1:], stdout, stderr)
+		case "restore":
+			return runRestore(args[1:], stdout, stderr)
+		case "sort":
+			return runSort(args[1:], stdout, stderr)
+		}
+	}
+
 	fs := pflag.NewFlagSet("tftidy", pflag.ContinueOnError)
 	fs.SortFlags = false
 	fs.SetOutput(stderr)
@@ -34,6 +55,30 @@ func run(args []string, stdout, stderr io.Writer) int {
 	verbose := fs.BoolP("verbose", "v", false, "Show each file being processed")
 	removeComments := fs.Bool("remove-comments", false, "Also remove leading comments attached to removed blocks")
 	normalizeWhitespace := fs.Bool("normalize-whitespace", false, "Normalize consecutive blank lines after removal")
+	rawDeleteEmpty := fs.String("delete-empty", "", "Delete files left with only whitespace after removal (\"comments\": or only comments)")
+	fs.Lookup("delete-empty").NoOptDefVal = deleteEmptyBlank
+	preserveMtime := fs.Bool("preserve-mtime", false, "Keep the modification time of rewritten files")
+	backupSuffix := fs.String("backup", "", "Keep the original of each modified file next to it with this suffix")
+	fs.Lookup("backup").NoOptDefVal = defaultBackupSuffix
+	backupDir := fs.String("backup-dir", "", "Keep the original of each modified file under this directory")
+	relocateTo := fs.String("relocate-to", "", "Move blocks into this file of each module instead of deleting them")
+	ledgerPath := fs.String("ledger", "", "Append every removed block to this JSON Lines file, e.g. \".tftidy/ledger.jsonl\"")
+	atomicRun := fs.Bool("atomic-run", false, "Modify no files unless every file can be processed and written")
+	useStdin := fs.Bool("stdin", false, "Read content from stdin and write the result to stdout")
+	stdinFilename := fs.String("stdin-filename", "stdin.tf", "File name used for stdin content")
+	filesFrom := fs.String("files-from", "", "Read the files to process from this file (\"-\" for stdin)")
+	nullSeparated := fs.BoolP("null", "0", false, "File list entries are separated by NUL instead of newline")
+	discovery := addDiscoveryFlags(fs)
+	changedSince := fs.String("changed-since", "", "Only process files changed since the merge base with this git ref")
+	includeStaged := fs.Bool("include-staged", false, "With --changed-since, also process files with staged changes")
+	includeUntracked := fs.Bool("include-untracked", false, "With --changed-since, also process untracked files")
+	terragrunt := fs.Bool("terragrunt", false, "Also clean generate block contents in terragrunt.hcl files")
+	markdown := fs.Bool("markdown", false, "Also clean hcl and terraform code fences in Markdown files")
+	skipShadowed := fs.Bool("skip-shadowed", false, "Skip .tf files shadowed by a .tofu file of the same name")
+	roots := fs.StringSlice("roots", nil, "Only process modules reachable from these root modules, comma-separated")
+	protectChildModules := fs.Bool("protect-child-modules", false, "Keep moved blocks in child modules")
+	childModulePaths := fs.StringArray("child-module-path", nil, "Treat modules under this path as child modules (repeatable)")
+	allowChildMoved := fs.StringArray("allow-moved-in", nil, "Remove moved blocks in this child module despite --protect-child-modules (repeatable)")
 	showVersion := fs.Bool("version", false, "Show version")
 	showHelp := fs.BoolP("help", "h", false, "Show help")
 
@@ -53,64 +98,240 @@ func run(args []string, stdout, stderr io.Writer) int {
 		return 0
 	}
 
-	remaining := fs.Args()
-	if len(remaining) > 1 {
-		writef(stderr, "Error: expected at most one directory argument\n\n")
-		printUsage(stderr)
+	blockTypes, err := parseBlockTypes(*rawTypes)
+	if err != nil {
+		writef(stderr, "Error: %v\n", err)
 		return 2
 	}
 
-	blockTypes, err := parseBlockTypes(*rawTypes)
+	deleteEmpty, err := parseDeleteEmpty(*rawDeleteEmpty)
 	if err != nil {
 		writef(stderr, "Error: %v\n", err)
 		return 2
 	}
 
-	dir := "."
-	if len(remaining) == 1 {
-		dir = remaining[0]
+	paths := fs.Args()
+	if len(paths) == 1 && paths[0] == "-" {
+		*useStdin = true
+		paths = nil
 	}
 
-	info, err := os.Stat(dir)
+	if *useStdin {
+		if len(paths) > 0 {
+			writef(stderr, "Error: paths cannot be combined with stdin input\n\n")
+			printUsage(stderr)
+			return 2
+		}
+		if *filesFrom == "-" {
+			writef(stderr, "Error: --files-from - cannot be combined with stdin input\n\n")
+			printUsage(stderr)
+			return 2
+		}
+		return runFilter(stdin, stdout, stderr, *stdinFilename, blockTypes, *removeComments, *normalizeWhitespace, *dryRun)
+	}
+
+	var listed []string
+	if *filesFrom != "" {
+		listed, err = readFileListFrom(*filesFrom, stdin, *nullSeparated)
+		if err != nil {
+			writef(stderr, "Error: failed to read file list: %v\n", err)
+			return 1
+		}
+	} else if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	discover, err := discovery.options()
 	if err != nil {
 		writef(stderr, "Error: %v\n", err)
 		return 1
 	}
-	if !info.IsDir() {
-		writef(stderr, "Error: %s is not a directory\n", dir)
+	discover.terragrunt = *terragrunt
+	discover.markdown = *markdown
+	discover.backupDir = *backupDir
+	if *verbose {
+		discover.skipped = func(path, reason string) {
+			writef(stdout, "Skipping: %s (%s)\n", path, reason)
+		}
+	}
+	files, err := resolveInputs(paths, listed, discover)
+	if err != nil {
+		writef(stderr, "Error: %v\n", err)
 		return 1
 	}
 
-	files, err := discoverFiles(dir)
+	writeRoots, err := scanRoots(paths, listed)
 	if err != nil {
-		writef(stderr, "Error: failed to discover Terraform files: %v\n", err)
+		writef(stderr, "Error: %v\n", err)
 		return 1
 	}
 
+	files, duplicateOf := dedupeRealPaths(files)
+	if *verbose {
+		for _, path := range sortedKeys(duplicateOf) {
+			writef(stdout, "Skipping duplicate: %s (same file as %s)\n", path, duplicateOf[path])
+		}
+	}
+
+	// Modules are classified before the file list is narrowed, and with the
+	// rest of the repository, so a child module is recognized even when its
+	// caller is not processed.
+	var modules map[string]*moduleInfo
+	if *protectChildModules {
+		scope := files
+		if top, err := gitTopLevel(gitWorkDir(paths)); err == nil {
+			repoFiles, err := discoverFiles(top)
+			if err != nil {
+				writef(stderr, "Error: failed to discover modules in %s: %v\n", top, err)
+				return 1
+			}
+			scope = append(repoFiles, files...)
+		} else {
+			writef(stderr, "Warning: not in a git repository; --protect-child-modules only sees module calls in the scanned files\n")
+		}
+		modules = classifyModules(scope, *childModulePaths)
+	}
+
+	if *changedSince != "" {
+		opts := changedFilesOptions{staged: *includeStaged, untracked: *includeUntracked}
+		files, err = filterChangedFiles(files, gitWorkDir(paths), *changedSince, opts)
+		if err != nil {
+			writef(stderr, "Error: failed to list changed files: %v\n", err)
+			return 1
+		}
+	}
+
+	if *skipShadowed {
+		var shadowedBy map[string]string
+		files, shadowedBy = splitShadowedFiles(files)
+		if *verbose {
+			for _, path := range sortedKeys(shadowedBy) {
+				writef(stdout, "Skipping shadowed: %s (shadowed by %s)\n", path, shadowedBy[path])
+			}
+		}
+	}
+
+	var unreachable []string
+	if len(*roots) > 0 {
+		files, unreachable, err = filterReachableFiles(files, *roots)
+		if err != nil {
+			writef(stderr, "Error: failed to resolve module graph: %v\n", err)
+			return 1
+		}
+	}
+
+	var relocate *relocation
+	if *relocateTo != "" {
+		relocate, err = newRelocation(*relocateTo)
+		if err != nil {
+			writef(stderr, "Error: %v\n", err)
+			return 2
+		}
+	}
+
+	backup := backupOptions{suffix: *backupSuffix, dir: *backupDir}
+	var changes []fileChange
+
+	var removals *ledger
+	if *ledgerPath != "" && !*dryRun {
+		removals, err = newLedger(*ledgerPath, gitWorkDir(paths))
+		if err != nil {
+			writef(stderr, "Error: failed to start ledger: %v\n", err)
+			return 1
+		}
+	}
+
 	st := stats{blockCounts: make(map[string]int, len(blockTypes))}
 	for _, blockType := range blockTypes {
 		st.blockCounts[blockType] = 0
 	}
 
+	// applyChange writes one file outside of an atomic run and reports
+	// whether it was written.
+	applyChange := func(change fileChange) bool {
+		if backup.enabled() && !change.created {
+			if err := writeBackup(change.path, change.original, backup); err != nil {
+				recordFileError(stderr, change.path, fmt.Errorf("failed to write backup: %w", err), &st)
+				return false
+			}
+		}
+
+		var err error
+		switch {
+		case change.deleted:
+			err = os.Remove(change.path)
+		case change.created:
+			err = createFileAtomic(change.path, change.updated)
+		default:
+			err = writeFileAtomic(change.path, change.updated, *preserveMtime)
+		}
+		if err != nil {
+			recordFileError(stderr, change.path, err, &st)
+			return false
+		}
+
+		countChange(&st, change)
+
+		if removals != nil && len(change.types) > 0 {
+			if err := removals.record(change.path, change.original, change.types); err != nil {
+				recordFileError(stderr, change.path, fmt.Errorf("failed to record removed blocks: %w", err), &st)
+			}
+		}
+		return true
+	}
+
 	for _, path := range files {
 		st.filesProcessed++
 		if *verbose {
 			writef(stdout, "Processing: %s\n", path)
 		}
 
-		fileInfo, err := os.Stat(path)
+		content, err := os.ReadFile(path)
 		if err != nil {
 			recordFileError(stderr, path, err, &st)
 			continue
 		}
 
-		content, err := os.ReadFile(path)
-		if err != nil {
-			recordFileError(stderr, path, err, &st)
+		fileTypes := blockTypes
+		if *protectChildModules {
+			module := moduleOf(modules, path)
+			if module != nil && module.kind == moduleChild && !isUnderAny(module.dir, *allowChildMoved) {
+				fileTypes = withoutBlockType(blockTypes, "moved")
+				if *verbose && len(fileTypes) != len(blockTypes) {
+					writef(stdout, "Keeping moved blocks in child module: %s\n", path)
+				}
+			}
+		}
+
+		if len(fileTypes) == 0 {
 			continue
 		}
 
-		updated, counts, err := removeBlocks(content, path, blockTypes, *removeComments)
+		var (
+			updated []byte
+			counts  map[string]int
+		)
+		switch {
+		case relocate == nil:
+			updated, counts, err = removeBlocks(content, path, fileTypes, *removeComments)
+		case relocate.isTarget(path):
+			continue
+		case isJSONFile(path) || isTerragruntFile(path) || isMarkdownFile(path):
+			if *verbose {
+				writef(stdout, "Skipping relocation from %s: only native-syntax files are supported\n", path)
+			}
+			continue
+		default:
+			// Headers of sections that are moved out entirely are not
+			// relocated; they are dropped like on removal.
+			source := content
+			if *removeComments {
+				source, err = removeOrphanedHeaders(content, path, fileTypes)
+			}
+			if err == nil {
+				updated, counts, err = relocate.take(source, path, fileTypes)
+			}
+		}
 		if err != nil {
 			recordFileError(stderr, path, err, &st)
 			continue
@@ -121,9 +342,8 @@ func run(args []string, stdout, stderr io.Writer) int {
 			continue
 		}
 
-		if *dryRun {
-			st.filesModified++
-			addCounts(&st, counts)
+		if err := checkWriteTarget(path, writeRoots); err != nil {
+			recordFileError(stderr, path, err, &st)
 			continue
 		}
 
@@ -131,13 +351,100 @@ func run(args []string, stdout, stderr io.Writer) int {
 			updated = normalizeConsecutiveNewlines(updated)
 		}
 
-		if err := os.WriteFile(path, updated, fileInfo.Mode().Perm()); err != nil {
-			recordFileError(stderr, path, err, &st)
+		change := fileChange{path: path, original: content, updated: updated, types: fileTypes, counts: counts}
+		if deleteEmpty != "" && isEmptyFile(updated, path, deleteEmpty == deleteEmptyComments) {
+			// Removing a symlink would leave the file it points to behind,
+			// so a symlinked file is emptied instead.
+			if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink == 0 {
+				change.deleted = true
+				if *verbose {
+					writef(stdout, "Deleting empty file: %s\n", path)
+				}
+			}
+		}
+
+		if *dryRun {
+			countChange(&st, change)
 			continue
 		}
+		if relocate != nil {
+			// Relocated blocks are not removed from the module, so they
+			// are not recorded in the ledger.
+			change.types = nil
+		}
 
-		st.filesModified++
-		addCounts(&st, counts)
+		// Relocation writes the targets before the files the blocks came
+		// from, so a failure never loses a block.
+		if *atomicRun || relocate != nil {
+			changes = append(changes, change)
+			continue
+		}
+
+		applyChange(change)
+	}
+
+	if relocate != nil {
+		// A module whose target cannot be written keeps its blocks where
+		// they are.
+		blockedDirs := make(map[string]struct{})
+		targets := make([]fileChange, 0)
+		for _, change := range relocate.changes() {
+			if err := checkWriteTarget(change.path, writeRoots); err != nil {
+				recordFileError(stderr, change.path, err, &st)
+				blockedDirs[filepath.Dir(change.path)] = struct{}{}
+				continue
+			}
+			if *dryRun {
+				st.filesModified++
+			}
+			targets = append(targets, change)
+		}
+		changes = append(targets, changes...)
+
+		if !*atomicRun && !*dryRun {
+			for _, change := range changes {
+				if _, blocked := blockedDirs[filepath.Dir(change.path)]; blocked {
+					continue
+				}
+				if !applyChange(change) && relocate.isTarget(change.path) {
+					blockedDirs[filepath.Dir(change.path)] = struct{}{}
+				}
+			}
+		}
+	}
+
+	if *atomicRun && !*dryRun {
+		switch {
+		case st.filesErrored > 0:
+			writef(stderr, "Aborted: %d file(s) failed, no files were modified\n", st.filesErrored)
+		case len(changes) > 0:
+			if err := commitChanges(changes, backup, *preserveMtime); err != nil {
+				writef(stderr, "Error: %v\n", err)
+				if errors.Is(err, errRollbackFailed) {
+					writef(stderr, "Aborted: some files could not be restored\n")
+				} else {
+					writef(stderr, "Aborted: no files were modified\n")
+				}
+				st.filesErrored++
+				break
+			}
+			for _, change := range changes {
+				countChange(&st, change)
+				if removals != nil && len(change.types) > 0 {
+					if err := removals.record(change.path, change.original, change.types); err != nil {
+						recordFileError(stderr, change.path, fmt.Errorf("failed to record removed blocks: %w", err), &st)
+					}
+				}
+			}
+		}
+	}
+
+	if len(unreachable) > 0 {
+		writeln(stdout, "Unreachable modules:")
+		for _, dir := range unreachable {
+			writef(stdout, "  %s\n", dir)
+		}
+		writeln(stdout)
 	}
 
 	printStats(stdout, st, blockTypes)
@@ -149,6 +456,63 @@ func run(args []string, stdout, stderr io.Writer) int {
 	return 0
 }
 
+// runFilter cleans content read from stdin and writes it to stdout, so editors
+// can pipe a buffer through tftidy. Stats and errors go to stderr.
+func runFilter(stdin io.Reader, stdout, stderr io.Writer, filename string, blockTypes []string, removeComments, normalizeWhitespace, dryRun bool) int {
+	st := stats{blockCounts: make(map[string]int, len(blockTypes))}
+	for _, blockType := range blockTypes {
+		st.blockCounts[blockType] = 0
+	}
+	st.filesProcessed++
+
+	content, err := io.ReadAll(stdin)
+	if err != nil {
+		recordFileError(stderr, filename, err, &st)
+		printStats(stderr, st, blockTypes)
+		return 1
+	}
+
+	updated, counts, err := removeBlocks(content, filename, blockTypes, removeComments)
+	if err != nil {
+		recordFileError(stderr, filename, err, &st)
+		printStats(stderr, st, blockTypes)
+		return 1
+	}
+
+	if sumCounts(counts) > 0 {
+		st.filesModified++
+		addCounts(&st, counts)
+		if normalizeWhitespace {
+			updated = normalizeConsecutiveNewlines(updated)
+		}
+	}
+
+	if dryRun {
+		updated = content
+	}
+
+	_, _ = stdout.Write(updated)
+	printStats(stderr, st, blockTypes)
+
+	return 0
+}
+
+func readFileListFrom(source string, stdin io.Reader, nul bool) ([]string, error) {
+	if source == "-" {
+		return readFileList(stdin, nul)
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return readFileList(f, nul)
+}
+
 func parseBlockTypes(raw string) ([]string, error) {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
@@ -200,10 +564,36 @@ func parseBlockTypes(raw string) ([]string, error) {
 	return result, nil
 }
 
+func withoutBlockType(blockTypes []string, excluded string) []string {
+	result := make([]string, 0, len(blockTypes))
+	for _, blockType := range blockTypes {
+		if blockType != excluded {
+			result = append(result, blockType)
+		}
+	}
+	return result
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func printUsage(w io.Writer) {
 	writeln(w, "tftidy - Remove transient blocks (moved, removed, import) from Terraform files")
 	writeln(w)
-	writeln(w, "Usage: tftidy [options] [directory]")
+	writeln(w, "Usage: tftidy [options] [path ...]")
+	writeln(w, "       tftidy <command> [options] [directory]")
+	writeln(w)
+	writeln(w, "Commands:")
+	writeln(w, "  check                          Report duplicate, conflicting, and version-incompatible transient blocks")
+	writeln(w, "  collapse-moves                 Collapse chained moved blocks into direct moves")
+	writeln(w, "  restore                        Re-insert blocks recorded in the removal ledger")
+	writeln(w, "  sort                           Sort transient blocks by type and address within each file")
 	writeln(w)
 	writeln(w, "Options:")
 	writeln(w, "  -t, --type string              Block types to remove, comma-separated (default \"moved,removed,import\")")
@@ -211,6 +601,28 @@ func printUsage(w io.Writer) {
 	writeln(w, "  -v, --verbose                  Show each file being processed")
 	writeln(w, "      --remove-comments          Also remove leading comments attached to removed blocks")
 	writeln(w, "      --normalize-whitespace     Normalize consecutive blank lines after removal")
+	writeln(w, "      --delete-empty[=comments]  Delete files left with only whitespace after removal (\"comments\": or only comments)")
+	writeln(w, "      --preserve-mtime           Keep the modification time of rewritten files")
+	writeln(w, "      --backup[=suffix]          Keep the original of each modified file next to it with this suffix (default \".bak\")")
+	writeln(w, "      --backup-dir dir           Keep the original of each modified file under this directory")
+	writeln(w, "      --relocate-to file         Move blocks into this file of each module instead of deleting them")
+	writeln(w, "      --ledger file              Append every removed block to this JSON Lines file, e.g. \".tftidy/ledger.jsonl\"")
+	writeln(w, "      --atomic-run               Modify no files unless every file can be processed and written")
+	writeln(w, "      --stdin                    Read content from stdin and write the result to stdout (same as a single \"-\" path)")
+	writeln(w, "      --stdin-filename string    File name used for stdin content (default \"stdin.tf\")")
+	writeln(w, "      --files-from file          Read the files to process from this file (\"-\" for stdin)")
+	writeln(w, "  -0, --null                     File list entries are separated by NUL instead of newline")
+	printDiscoveryUsage(w)
+	writeln(w, "      --changed-since ref        Only process files changed since the merge base with this git ref")
+	writeln(w, "      --include-staged           With --changed-since, also process files with staged changes")
+	writeln(w, "      --include-untracked        With --changed-since, also process untracked files")
+	writeln(w, "      --terragrunt               Also clean generate block contents in terragrunt.hcl files")
+	writeln(w, "      --markdown                 Also clean hcl and terraform code fences in Markdown files")
+	writeln(w, "      --skip-shadowed            Skip .tf files shadowed by a .tofu file of the same name")
+	writeln(w, "      --roots strings            Only process modules reachable from these root modules, comma-separated")
+	writeln(w, "      --protect-child-modules    Keep moved blocks in child modules")
+	writeln(w, "      --child-module-path path   Treat modules under this path as child modules (repeatable)")
+	writeln(w, "      --allow-moved-in path      Remove moved blocks in this child module despite --protect-child-modules (repeatable)")
 	writeln(w, "      --version                  Show version")
 	writeln(w, "  -h, --help                     Show help")
 }
@@ -220,6 +632,16 @@ func recordFileError(stderr io.Writer, path string, err error, st *stats) {
 	writef(stderr, "Error processing %s: %v\n", path, err)
 }
 
+// countChange adds a written or deleted file and its removed blocks to st.
+func countChange(st *stats, change fileChange) {
+	if change.deleted {
+		st.filesDeleted++
+	} else {
+		st.filesModified++
+	}
+	addCounts(st, change.counts)
+}
+
 func addCounts(st *stats, counts map[string]int) {
 	for blockType, count := range counts {
 		st.blockCounts[blockType] += count
@@ -237,6 +659,7 @@ func sumCounts(counts map[string]int) int {
 func printStats(stdout io.Writer, st stats, blockTypes []string) {
 	writef(stdout, "Files processed: %d\n", st.filesProcessed)
 	writef(stdout, "Files modified: %d\n", st.filesModified)
+	writef(stdout, "Files deleted: %d\n", st.filesDeleted)
 	writef(stdout, "Files errored: %d\n", st.filesErrored)
 	writeln(stdout)
 	writeln(stdout, "Blocks removed:")
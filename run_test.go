@@ -45,7 +45,7 @@ func TestRunHelp(t *testing.T) {
 	if stderr.Len() != 0 {
 		t.Fatalf("unexpected stderr: %s", stderr.String())
 	}
-	if !strings.Contains(stdout.String(), "Usage: tftidy [options] [directory]") {
+	if !strings.Contains(stdout.String(), "Usage: tftidy [options] [path ...]") {
 		t.Fatalf("help output is missing usage: %s", stdout.String())
 	}
 }
@@ -86,17 +86,19 @@ func TestRunNonExistentDirectory(t *testing.T) {
 	}
 }
 
-func TestRunTooManyArgs(t *testing.T) {
+func TestRunMultiplePathsMissing(t *testing.T) {
 	t.Parallel()
 
+	tempDir := t.TempDir()
+
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
-	code := run([]string{"one", "two"}, &stdout, &stderr)
+	code := run([]string{tempDir, filepath.Join(tempDir, "missing.tf")}, &stdout, &stderr)
 
-	if code != 2 {
-		t.Fatalf("expected exit code 2, got %d", code)
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
 	}
-	if !strings.Contains(stderr.String(), "expected at most one directory argument") {
+	if !strings.Contains(stderr.String(), "missing.tf") {
 		t.Fatalf("unexpected stderr: %s", stderr.String())
 	}
 }
@@ -141,23 +143,217 @@ func TestParseBlockTypes(t *testing.T) {
 	}
 }
 
-func TestRunNotDirectory(t *testing.T) {
+func TestRunFilesAndDirectories(t *testing.T) {
 	t.Parallel()
 
 	tempDir := t.TempDir()
-	file := filepath.Join(tempDir, "main.tf")
-	if err := os.WriteFile(file, []byte("resource \"null_resource\" \"x\" {}\n"), 0o644); err != nil {
-		t.Fatalf("failed to write file: %v", err)
+	moved := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+
+	mustMkdirAll(t, filepath.Join(tempDir, "stack"))
+	mustMkdirAll(t, filepath.Join(tempDir, "other"))
+	stackFile := filepath.Join(tempDir, "stack", "main.tf")
+	ignoredFile := filepath.Join(tempDir, "other", "ignored.tf")
+	untouchedFile := filepath.Join(tempDir, "other", "untouched.tf")
+	mustWriteFile(t, stackFile, moved, 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "other", ".gitignore"), "ignored.tf\n", 0o644)
+	mustWriteFile(t, ignoredFile, moved, 0o644)
+	mustWriteFile(t, untouchedFile, moved, 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--verbose", ignoredFile, filepath.Join(tempDir, "stack"), stackFile, ignoredFile}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+
+	expected := "Processing: " + ignoredFile + "\nProcessing: " + stackFile + "\n"
+	if !strings.HasPrefix(stdout.String(), expected) {
+		t.Fatalf("files should be deduplicated and sorted\nexpected prefix:\n%s\nactual:\n%s", expected, stdout.String())
+	}
+
+	for path, wantMoved := range map[string]bool{stackFile: false, ignoredFile: false, untouchedFile: true} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		if got := containsBlockDeclaration(string(content), "moved"); got != wantMoved {
+			t.Fatalf("unexpected moved block presence in %s: expected %v", path, wantMoved)
+		}
+	}
+}
+
+func TestRunFileArgumentsUnsupportedTypes(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	moved := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+	mainFile := filepath.Join(tempDir, "main.tf")
+	notesFile := filepath.Join(tempDir, "notes.txt")
+	readmeFile := filepath.Join(tempDir, "README.md")
+	terragruntFile := filepath.Join(tempDir, "terragrunt.hcl")
+	mustWriteFile(t, mainFile, moved, 0o644)
+	mustWriteFile(t, notesFile, "not HCL {\n", 0o644)
+	mustWriteFile(t, readmeFile, "```hcl\n"+moved+"```\n", 0o644)
+	mustWriteFile(t, terragruntFile, "generate \"moved\" {\n  path     = \"moved.tf\"\n  contents = <<EOF\n"+moved+"EOF\n}\n", 0o644)
+
+	tests := []struct {
+		name      string
+		args      []string
+		processed []string
+	}{
+		{name: "default", processed: []string{mainFile}},
+		{name: "opt in", args: []string{"--markdown", "--terragrunt"}, processed: []string{readmeFile, mainFile, terragruntFile}},
+	}
+
+	for _, tt := range tests {
+		var stdout bytes.Buffer
+		var stderr bytes.Buffer
+		args := append(append([]string{"--verbose", "--dry-run"}, tt.args...), mainFile, notesFile, readmeFile, terragruntFile)
+		code := run(args, &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("%s: expected exit code 0, got %d stderr=%s", tt.name, code, stderr.String())
+		}
+		if got := processedFiles(stdout.String()); strings.Join(got, ",") != strings.Join(tt.processed, ",") {
+			t.Fatalf("%s: unexpected processed files\nexpected: %v\nactual: %v", tt.name, tt.processed, got)
+		}
+		if !strings.Contains(stdout.String(), "Skipping: "+notesFile+" (unsupported file type)") {
+			t.Fatalf("%s: unsupported file should be reported:\n%s", tt.name, stdout.String())
+		}
+	}
+}
+
+func TestRunStdinFilter(t *testing.T) {
+	t.Parallel()
+
+	input := `resource "aws_instance" "main" {
+  ami = "ami-123456"
+}
+
+moved {
+  from = aws_instance.old
+  to   = aws_instance.main
+}
+`
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "dash", args: []string{"-"}},
+		{name: "flag", args: []string{"--stdin", "--stdin-filename", "main.tf"}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stdout bytes.Buffer
+			var stderr bytes.Buffer
+			code := runWithStdin(tc.args, strings.NewReader(input), &stdout, &stderr)
+			if code != 0 {
+				t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+			}
+
+			expected := "resource \"aws_instance\" \"main\" {\n  ami = \"ami-123456\"\n}\n\n"
+			if stdout.String() != expected {
+				t.Fatalf("unexpected stdout\nexpected:\n%q\nactual:\n%q", expected, stdout.String())
+			}
+			if !strings.Contains(stderr.String(), "Files modified: 1") {
+				t.Fatalf("stats should be written to stderr: %s", stderr.String())
+			}
+		})
 	}
+}
+
+func TestRunStdinParseError(t *testing.T) {
+	t.Parallel()
 
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
-	code := run([]string{file}, &stdout, &stderr)
+	code := runWithStdin([]string{"--stdin-filename", "buffer.tf", "-"}, strings.NewReader("moved {"), &stdout, &stderr)
 
 	if code != 1 {
 		t.Fatalf("expected exit code 1, got %d", code)
 	}
-	if !strings.Contains(stderr.String(), "is not a directory") {
+	if stdout.Len() != 0 {
+		t.Fatalf("expected no stdout, got: %s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "Error processing buffer.tf") {
 		t.Fatalf("unexpected stderr: %s", stderr.String())
 	}
 }
+
+func TestRunStdinWithPaths(t *testing.T) {
+	t.Parallel()
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := runWithStdin([]string{"--stdin", "."}, strings.NewReader(""), &stdout, &stderr)
+
+	if code != 2 {
+		t.Fatalf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "paths cannot be combined with stdin input") {
+		t.Fatalf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+func TestRunFilesFromStdinNul(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	moved := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+	listedFile := filepath.Join(tempDir, "listed.tf")
+	unlistedFile := filepath.Join(tempDir, "unlisted.tf")
+	mustWriteFile(t, listedFile, moved, 0o644)
+	mustWriteFile(t, unlistedFile, moved, 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "notes.txt"), "moved {\n", 0o644)
+
+	list := listedFile + "\x00" + filepath.Join(tempDir, "notes.txt") + "\x00" + filepath.Join(tempDir, "deleted.tf") + "\x00"
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := runWithStdin([]string{"--files-from", "-", "-0"}, strings.NewReader(list), &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("expected exit code 1 for missing listed file, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "deleted.tf") {
+		t.Fatalf("missing file should be reported: %s", stderr.String())
+	}
+
+	out := stdout.String()
+	if !strings.Contains(out, "Files processed: 2") || !strings.Contains(out, "Files modified: 1") || !strings.Contains(out, "Files errored: 1") {
+		t.Fatalf("unexpected stats: %s", out)
+	}
+
+	for path, wantMoved := range map[string]bool{listedFile: false, unlistedFile: true} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		if got := containsBlockDeclaration(string(content), "moved"); got != wantMoved {
+			t.Fatalf("unexpected moved block presence in %s: expected %v", path, wantMoved)
+		}
+	}
+}
+
+func TestReadFileList(t *testing.T) {
+	t.Parallel()
+
+	got, err := readFileList(strings.NewReader("a.tf\r\n\nb/c.tf\n"), false)
+	if err != nil {
+		t.Fatalf("readFileList failed: %v", err)
+	}
+	if strings.Join(got, ",") != "a.tf,b/c.tf" {
+		t.Fatalf("unexpected list: %v", got)
+	}
+
+	got, err = readFileList(strings.NewReader("with\nnewline.tf\x00d.tf"), true)
+	if err != nil {
+		t.Fatalf("readFileList failed: %v", err)
+	}
+	if len(got) != 2 || got[0] != "with\nnewline.tf" {
+		t.Fatalf("unexpected NUL-separated list: %q", got)
+	}
+}
@@ -0,0 +1,178 @@
+package tftidy
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/spf13/pflag"
+)
+
+func runSort(args []string, stdout, stderr io.Writer) int {
+	fs := pflag.NewFlagSet("tftidy sort", pflag.ContinueOnError)
+	fs.SortFlags = false
+	fs.SetOutput(stderr)
+
+	rawTypes := fs.StringP("type", "t", "moved,removed,import", "Block types to sort, comma-separated, in group order")
+	dryRun := fs.BoolP("dry-run", "n", false, "Preview changes without modifying files")
+	verbose := fs.BoolP("verbose", "v", false, "Show each file being processed")
+	discovery := addDiscoveryFlags(fs)
+	showHelp := fs.BoolP("help", "h", false, "Show help")
+
+	if err := fs.Parse(args); err != nil {
+		writef(stderr, "Error: %v\n\n", err)
+		printSortUsage(stderr)
+		return 2
+	}
+
+	if *showHelp {
+		printSortUsage(stdout)
+		return 0
+	}
+
+	blockTypes, err := parseBlockTypes(*rawTypes)
+	if err != nil {
+		writef(stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	remaining := fs.Args()
+	if len(remaining) > 1 {
+		writef(stderr, "Error: expected at most one directory argument\n\n")
+		printSortUsage(stderr)
+		return 2
+	}
+
+	dir := "."
+	if len(remaining) == 1 {
+		dir = remaining[0]
+	}
+
+	discover, err := discovery.options()
+	if err != nil {
+		writef(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if *verbose {
+		discover.skipped = func(path, reason string) {
+			writef(stdout, "Skipping: %s (%s)\n", path, reason)
+		}
+	}
+	files, err := walkFiles(dir, discover, isTerraformFile)
+	if err != nil {
+		writef(stderr, "Error: failed to discover Terraform files: %v\n", err)
+		return 1
+	}
+	files = nativeSyntaxFiles(files)
+
+	errored := 0
+	sorted := 0
+	for _, path := range files {
+		if *verbose {
+			writef(stdout, "Processing: %s\n", path)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			errored++
+			writef(stderr, "Error processing %s: %v\n", path, err)
+			continue
+		}
+
+		updated, err := sortTransientBlocks(content, path, blockTypes)
+		if err != nil {
+			errored++
+			writef(stderr, "Error processing %s: %v\n", path, err)
+			continue
+		}
+		if bytes.Equal(updated, content) {
+			continue
+		}
+
+		if !*dryRun {
+			if err := writeFileAtomic(path, updated, false); err != nil {
+				errored++
+				writef(stderr, "Error processing %s: %v\n", path, err)
+				continue
+			}
+		}
+		sorted++
+		writef(stdout, "Sorted: %s\n", path)
+	}
+
+	writeln(stdout)
+	writef(stdout, "Files sorted: %d\n", sorted)
+
+	if errored > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// sortTransientBlocks reorders the top-level blocks of blockTypes in content:
+// grouped in the order of blockTypes, then by address. Each block keeps the
+// comment lines directly above it and any comment after its closing brace.
+// The sorted blocks fill the positions the blocks of those types held before,
+// so all other content stays where it is.
+func sortTransientBlocks(content []byte, filename string, blockTypes []string) ([]byte, error) {
+	blocks, err := collectTransientBlocks(content, filename, blockTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := blockByteRanges(blocks)
+	for i, r := range slots {
+		start := lineStart(content, r.start)
+		if len(bytes.TrimSpace(content[start:r.start])) == 0 {
+			slots[i].start = leadingCommentStart(content, start)
+		}
+		if end := bytes.IndexByte(content[r.end:], '\n'); end >= 0 {
+			slots[i].end = r.end + end
+		} else {
+			slots[i].end = len(content)
+		}
+	}
+
+	rank := make(map[string]int, len(blockTypes))
+	for i, blockType := range blockTypes {
+		rank[blockType] = i
+	}
+	order := make([]int, len(blocks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := blocks[order[i]], blocks[order[j]]
+		if rank[a.blockType] != rank[b.blockType] {
+			return rank[a.blockType] < rank[b.blockType]
+		}
+		return a.address < b.address
+	})
+
+	var buf bytes.Buffer
+	prev := 0
+	for i, slot := range slots {
+		buf.Write(content[prev:slot.start])
+		moved := slots[order[i]]
+		buf.Write(content[moved.start:moved.end])
+		prev = slot.end
+	}
+	buf.Write(content[prev:])
+
+	return buf.Bytes(), nil
+}
+
+func printSortUsage(w io.Writer) {
+	writeln(w, "tftidy sort - Sort transient blocks by type and address within each file")
+	writeln(w)
+	writeln(w, "Usage: tftidy sort [options] [directory]")
+	writeln(w)
+	writeln(w, "Options:")
+	writeln(w, "  -t, --type string              Block types to sort, comma-separated, in group order (default \"moved,removed,import\")")
+	writeln(w, "  -n, --dry-run                  Preview changes without modifying files")
+	writeln(w, "  -v, --verbose                  Show each file being processed")
+	printDiscoveryUsage(w)
+	writeln(w, "  -h, --help                     Show help")
+}
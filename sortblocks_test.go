@@ -0,0 +1,119 @@
+package tftidy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSortTransientBlocks(t *testing.T) {
+	t.Parallel()
+
+	input := `terraform {
+  required_version = ">= 1.7"
+}
+
+import {
+  to = aws_instance.b
+  id = "i-2"
+}
+
+# Renamed the web server.
+moved {
+  from = aws_instance.web
+  to   = aws_instance.main
+}
+
+resource "aws_instance" "main" {}
+
+moved {
+  from = aws_instance.app # legacy name
+  to   = aws_instance.main
+} # keep until v3
+
+removed {
+  from = aws_instance.gone
+}
+`
+
+	output, err := sortTransientBlocks([]byte(input), "moved.tf", allowedBlockTypes)
+	if err != nil {
+		t.Fatalf("sortTransientBlocks failed: %v", err)
+	}
+
+	expected := `terraform {
+  required_version = ">= 1.7"
+}
+
+moved {
+  from = aws_instance.app # legacy name
+  to   = aws_instance.main
+} # keep until v3
+
+# Renamed the web server.
+moved {
+  from = aws_instance.web
+  to   = aws_instance.main
+}
+
+resource "aws_instance" "main" {}
+
+removed {
+  from = aws_instance.gone
+}
+
+import {
+  to = aws_instance.b
+  id = "i-2"
+}
+`
+	if string(output) != expected {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", expected, string(output))
+	}
+
+	again, err := sortTransientBlocks(output, "moved.tf", allowedBlockTypes)
+	if err != nil {
+		t.Fatalf("sortTransientBlocks failed: %v", err)
+	}
+	if !bytes.Equal(again, output) {
+		t.Fatalf("sorting must be idempotent:\n%s", string(again))
+	}
+}
+
+func TestIntegrationRunSort(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "moved.tf")
+	input := "moved {\n  from = b.x\n  to   = b.y\n}\n\nmoved {\n  from = a.x\n  to   = a.y\n}\n"
+	mustWriteFile(t, file, input, 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "main.tf"), "moved {\n  from = a.x\n  to   = a.y\n}\n", 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"sort", "--dry-run", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Sorted: "+file) || !strings.Contains(stdout.String(), "Files sorted: 1") {
+		t.Fatalf("unexpected output:\n%s", stdout.String())
+	}
+	if content, _ := os.ReadFile(file); string(content) != input {
+		t.Fatalf("dry-run must not modify files:\n%s", string(content))
+	}
+
+	stdout.Reset()
+	code = run([]string{"sort", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "moved {\n  from = a.x\n  to   = a.y\n}\n\nmoved {\n  from = b.x\n  to   = b.y\n}\n" {
+		t.Fatalf("unexpected content:\n%s", string(content))
+	}
+}
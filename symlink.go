@@ -0,0 +1,83 @@
+package tftidy
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// scanRoots returns the resolved directories files may be written in: each
+// directory argument and the parent of each file argument or listed file.
+func scanRoots(paths []string, listed []string) ([]string, error) {
+	roots := make([]string, 0, len(paths)+len(listed))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			path = filepath.Dir(path)
+		}
+
+		root, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+	}
+
+	for _, path := range listed {
+		// A listed file may not exist; it is reported when processed.
+		if root, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+			roots = append(roots, root)
+		}
+	}
+
+	return roots, nil
+}
+
+// dedupeRealPaths drops files that resolve to the same real path as an
+// earlier file, so a file reachable through several symlinks is processed
+// once. duplicateOf maps each dropped file to the one that is kept. Files that
+// cannot be resolved are kept as they are.
+func dedupeRealPaths(files []string) ([]string, map[string]string) {
+	kept := make([]string, 0, len(files))
+	duplicateOf := make(map[string]string)
+	firstByReal := make(map[string]string, len(files))
+	for _, path := range files {
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			kept = append(kept, path)
+			continue
+		}
+		if first, ok := firstByReal[real]; ok {
+			duplicateOf[path] = first
+			continue
+		}
+		firstByReal[real] = path
+		kept = append(kept, path)
+	}
+
+	return kept, duplicateOf
+}
+
+// checkWriteTarget refuses to write path when it resolves, through symlinks,
+// to a file outside every scan root.
+func checkWriteTarget(path string, roots []string) error {
+	real, err := filepath.EvalSymlinks(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// A file that is about to be created is checked by its directory.
+		var dir string
+		dir, err = filepath.EvalSymlinks(filepath.Dir(path))
+		real = filepath.Join(dir, filepath.Base(path))
+	}
+	if err != nil {
+		return err
+	}
+	if !isUnderAny(filepath.Dir(real), roots) {
+		return fmt.Errorf("refusing to write through symlink to %s outside the scan root", real)
+	}
+	return nil
+}
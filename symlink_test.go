@@ -0,0 +1,146 @@
+package tftidy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustSymlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatalf("failed to create symlink %s: %v", link, err)
+	}
+}
+
+func TestDedupeRealPaths(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	mustMkdirAll(t, filepath.Join(tempDir, "shared"))
+	mustWriteFile(t, filepath.Join(tempDir, "shared", "main.tf"), "", 0o644)
+	mustSymlink(t, filepath.Join(tempDir, "shared"), filepath.Join(tempDir, "stack"))
+
+	files := []string{
+		filepath.Join(tempDir, "missing.tf"),
+		filepath.Join(tempDir, "shared", "main.tf"),
+		filepath.Join(tempDir, "stack", "main.tf"),
+	}
+	kept, duplicateOf := dedupeRealPaths(files)
+
+	if !reflect.DeepEqual(kept, files[:2]) {
+		t.Fatalf("unexpected kept files: %#v", kept)
+	}
+	if duplicateOf[files[2]] != files[1] || len(duplicateOf) != 1 {
+		t.Fatalf("unexpected duplicates: %#v", duplicateOf)
+	}
+}
+
+func TestCheckWriteTarget(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	mustWriteFile(t, filepath.Join(root, "main.tf"), "", 0o644)
+	mustWriteFile(t, filepath.Join(outside, "main.tf"), "", 0o644)
+	mustSymlink(t, filepath.Join(root, "main.tf"), filepath.Join(root, "inside.tf"))
+	mustSymlink(t, filepath.Join(outside, "main.tf"), filepath.Join(root, "outside.tf"))
+
+	roots, err := scanRoots([]string{root}, nil)
+	if err != nil {
+		t.Fatalf("scanRoots failed: %v", err)
+	}
+
+	for _, name := range []string{"main.tf", "inside.tf"} {
+		if err := checkWriteTarget(filepath.Join(root, name), roots); err != nil {
+			t.Fatalf("%s should be writable: %v", name, err)
+		}
+	}
+	err = checkWriteTarget(filepath.Join(root, "outside.tf"), roots)
+	if err == nil || !strings.Contains(err.Error(), "outside the scan root") {
+		t.Fatalf("expected refusal for outside.tf, got %v", err)
+	}
+}
+
+func TestWalkFilesFollowSymlinks(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	mustMkdirAll(t, filepath.Join(tempDir, "shared"))
+	mustWriteFile(t, filepath.Join(tempDir, "shared", "main.tf"), "", 0o644)
+	mustSymlink(t, filepath.Join(tempDir, "shared"), filepath.Join(tempDir, "stack"))
+	mustSymlink(t, filepath.Join(tempDir, "shared", "main.tf"), filepath.Join(tempDir, "link.tf"))
+	mustSymlink(t, tempDir, filepath.Join(tempDir, "shared", "loop"))
+	mustSymlink(t, filepath.Join(tempDir, "missing.tf"), filepath.Join(tempDir, "broken.tf"))
+
+	skipped := make(map[string]string)
+	record := func(path, reason string) { skipped[filepath.Base(path)] = reason }
+
+	files, err := walkFiles(tempDir, discoverOptions{skipped: record}, isTerraformFile)
+	if err != nil {
+		t.Fatalf("walkFiles failed: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{filepath.Join(tempDir, "shared", "main.tf")}) {
+		t.Fatalf("symlinks must not be followed by default: %#v", files)
+	}
+	if skipped["stack"] != "symlink; use --follow-symlinks to follow" {
+		t.Fatalf("unexpected skip reasons: %#v", skipped)
+	}
+
+	skipped = make(map[string]string)
+	files, err = walkFiles(tempDir, discoverOptions{followSymlinks: true, skipped: record}, isTerraformFile)
+	if err != nil {
+		t.Fatalf("walkFiles failed: %v", err)
+	}
+	expected := []string{
+		filepath.Join(tempDir, "link.tf"),
+		filepath.Join(tempDir, "shared", "main.tf"),
+		filepath.Join(tempDir, "stack", "main.tf"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("unexpected files\nexpected: %#v\nactual: %#v", expected, files)
+	}
+	if skipped["loop"] != "symlink cycle" || skipped["broken.tf"] != "broken symlink" {
+		t.Fatalf("unexpected skip reasons: %#v", skipped)
+	}
+}
+
+func TestIntegrationRunSymlinks(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	moved := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+	mustMkdirAll(t, filepath.Join(root, "shared"))
+	mustWriteFile(t, filepath.Join(root, "shared", "main.tf"), moved, 0o644)
+	mustSymlink(t, filepath.Join(root, "shared"), filepath.Join(root, "stack"))
+	mustWriteFile(t, filepath.Join(outside, "main.tf"), moved, 0o644)
+	mustSymlink(t, filepath.Join(outside, "main.tf"), filepath.Join(root, "outside.tf"))
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--follow-symlinks", "--verbose", root}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "outside the scan root") {
+		t.Fatalf("write outside the root should be refused: %s", stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Skipping duplicate: "+filepath.Join(root, "stack", "main.tf")) {
+		t.Fatalf("symlinked copy should be reported as duplicate:\n%s", stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "Files processed: 2") || !strings.Contains(stdout.String(), "Files modified: 1") {
+		t.Fatalf("unexpected stats:\n%s", stdout.String())
+	}
+
+	content, err := os.ReadFile(filepath.Join(outside, "main.tf"))
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != moved {
+		t.Fatalf("file outside the root must not be modified: %q", string(content))
+	}
+}
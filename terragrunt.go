@@ -0,0 +1,192 @@
+package tftidy
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// heredoc locates the body of a heredoc template in its file. The body spans
+// from the line after the opening marker up to the start of the line holding
+// the closing marker.
+type heredoc struct {
+	body     byteRange
+	line     int
+	indented bool
+}
+
+// removeTerragruntBlocks removes transient blocks from the Terraform code
+// embedded in the heredoc contents of top-level generate blocks. The rest of
+// the Terragrunt file is left untouched.
+func removeTerragruntBlocks(content []byte, filename string, blockTypes []string, removeComments bool) ([]byte, map[string]int, error) {
+	heredocs, err := generateHeredocs(content, filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	counts := make(map[string]int, len(blockTypes))
+	result := append([]byte(nil), content...)
+	for i := len(heredocs) - 1; i >= 0; i-- {
+		h := heredocs[i]
+		body := result[h.body.start:h.body.end]
+
+		indent := ""
+		if h.indented {
+			indent = commonIndent(body)
+			body = reindent(body, indent, "")
+		}
+
+		label := fmt.Sprintf("%s:%d", filename, h.line)
+		updated, bodyCounts, err := removeBlocks(body, label, blockTypes, removeComments)
+		if err != nil {
+			return nil, nil, err
+		}
+		if sumCounts(bodyCounts) == 0 {
+			continue
+		}
+
+		if h.indented {
+			updated = reindent(updated, "", indent)
+		}
+
+		spliced := make([]byte, 0, len(result)-len(body)+len(updated))
+		spliced = append(spliced, result[:h.body.start]...)
+		spliced = append(spliced, updated...)
+		spliced = append(spliced, result[h.body.end:]...)
+		result = spliced
+
+		for blockType, count := range bodyCounts {
+			counts[blockType] += count
+		}
+	}
+
+	if sumCounts(counts) == 0 {
+		return content, map[string]int{}, nil
+	}
+
+	return result, counts, nil
+}
+
+// collectTerragruntBlocks returns the transient blocks removeTerragruntBlocks
+// would remove, with lines counted in the Terragrunt file.
+func collectTerragruntBlocks(content []byte, filename string, blockTypes []string) ([]*transientBlock, error) {
+	heredocs, err := generateHeredocs(content, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]*transientBlock, 0)
+	for _, h := range heredocs {
+		found, err := collectEmbeddedBlocks(content[h.body.start:h.body.end], filename, h.line, blockTypes)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, found...)
+	}
+	return blocks, nil
+}
+
+// generateHeredocs returns the heredoc contents of the top-level generate
+// blocks in a Terragrunt file, in source order.
+func generateHeredocs(content []byte, filename string) ([]heredoc, error) {
+	syntaxFile, diags := hclsyntax.ParseConfig(content, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse %s: %s", filename, diags.Error())
+	}
+
+	syntaxBody, ok := syntaxFile.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("unexpected HCL body type in %s", filename)
+	}
+
+	heredocs := make([]heredoc, 0)
+	for _, block := range syntaxBody.Blocks {
+		if block.Type != "generate" {
+			continue
+		}
+		attr, ok := block.Body.Attributes["contents"]
+		if !ok {
+			continue
+		}
+		if h, ok := findHeredoc(content, attr.Expr.Range()); ok {
+			heredocs = append(heredocs, h)
+		}
+	}
+	return heredocs, nil
+}
+
+// collectEmbeddedBlocks returns the transient blocks in Terraform code
+// embedded in filename, whose first line is line of the file. Common
+// indentation is stripped before parsing, and block lines are counted in the
+// enclosing file.
+func collectEmbeddedBlocks(body []byte, filename string, line int, blockTypes []string) ([]*transientBlock, error) {
+	label := fmt.Sprintf("%s:%d", filename, line)
+	blocks, err := collectTransientBlocks(reindent(body, commonIndent(body), ""), label, blockTypes)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		b.path = filename
+		b.rng.Start.Line += line - 1
+		b.rng.End.Line += line - 1
+	}
+	return blocks, nil
+}
+
+// findHeredoc returns the body of the heredoc spanning r, if r is one.
+func findHeredoc(content []byte, r hcl.Range) (heredoc, bool) {
+	src := r.SliceBytes(content)
+	if !bytes.HasPrefix(src, []byte("<<")) {
+		return heredoc{}, false
+	}
+
+	firstNewline := bytes.IndexByte(src, '\n')
+	lastNewline := bytes.LastIndexByte(src, '\n')
+	if firstNewline < 0 || lastNewline < firstNewline {
+		return heredoc{}, false
+	}
+
+	return heredoc{
+		body:     byteRange{start: r.Start.Byte + firstNewline + 1, end: r.Start.Byte + lastNewline + 1},
+		line:     r.Start.Line + 1,
+		indented: bytes.HasPrefix(src, []byte("<<-")),
+	}, true
+}
+
+// commonIndent returns the leading whitespace shared by every non-blank line
+// of body, which is what an indented heredoc strips.
+func commonIndent(body []byte) string {
+	indent := ""
+	first := true
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lead := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			indent = lead
+			first = false
+			continue
+		}
+		for !strings.HasPrefix(lead, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+	return indent
+}
+
+// reindent replaces the prefix from with to on every non-blank line of body.
+func reindent(body []byte, from, to string) []byte {
+	lines := strings.Split(string(body), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = strings.TrimLeft(line, " \t")
+			continue
+		}
+		lines[i] = to + strings.TrimPrefix(line, from)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
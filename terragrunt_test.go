@@ -0,0 +1,157 @@
+package tftidy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemoveTerragruntBlocksIndentedHeredoc(t *testing.T) {
+	t.Parallel()
+
+	input := `include "root" {
+  path = find_in_parent_folders()
+}
+
+generate "moves" {
+  path      = "moves.tf"
+  if_exists = "overwrite"
+  contents  = <<-EOF
+    resource "aws_instance" "main" {
+      ami = "${local.ami}"
+    }
+
+    moved {
+      from = aws_instance.old
+      to   = aws_instance.main
+    }
+  EOF
+}
+
+generate "provider" {
+  path     = "provider.tf"
+  contents = <<EOF
+provider "aws" {
+  region = "us-east-1"
+}
+
+import {
+  to = aws_instance.main
+  id = "i-123"
+}
+EOF
+}
+`
+
+	output, counts, err := removeBlocks([]byte(input), filepath.Join("live", "terragrunt.hcl"), allowedBlockTypes, false)
+	if err != nil {
+		t.Fatalf("removeBlocks failed: %v", err)
+	}
+	if counts["moved"] != 1 || counts["import"] != 1 {
+		t.Fatalf("unexpected counts: %#v", counts)
+	}
+
+	expected := `include "root" {
+  path = find_in_parent_folders()
+}
+
+generate "moves" {
+  path      = "moves.tf"
+  if_exists = "overwrite"
+  contents  = <<-EOF
+    resource "aws_instance" "main" {
+      ami = "${local.ami}"
+    }
+
+  EOF
+}
+
+generate "provider" {
+  path     = "provider.tf"
+  contents = <<EOF
+provider "aws" {
+  region = "us-east-1"
+}
+
+EOF
+}
+`
+	if string(output) != expected {
+		t.Fatalf("unexpected output\nexpected:\n%s\nactual:\n%s", expected, string(output))
+	}
+}
+
+func TestRemoveTerragruntBlocksInvalidHeredoc(t *testing.T) {
+	t.Parallel()
+
+	input := `generate "broken" {
+  path     = "broken.tf"
+  contents = <<EOF
+moved {
+EOF
+}
+`
+
+	_, _, err := removeBlocks([]byte(input), "terragrunt.hcl", allowedBlockTypes, false)
+	if err == nil {
+		t.Fatal("expected parse error, got nil")
+	}
+	if !strings.Contains(err.Error(), "terragrunt.hcl:4") {
+		t.Fatalf("error should point into the heredoc: %v", err)
+	}
+}
+
+func TestIntegrationRunTerragruntOptIn(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "terragrunt.hcl")
+	input := `generate "moves" {
+  path     = "moves.tf"
+  contents = <<EOF
+moved {
+  from = aws_instance.old
+  to   = aws_instance.main
+}
+EOF
+}
+`
+	mustWriteFile(t, file, input, 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "other.hcl"), "x = 1\n", 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != input {
+		t.Fatalf("terragrunt.hcl must not be processed without --terragrunt")
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"--terragrunt", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Files processed: 1") {
+		t.Fatalf("only terragrunt.hcl should be processed:\n%s", stdout.String())
+	}
+	content, err = os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if strings.Contains(string(content), "moved {") {
+		t.Fatalf("moved block should be removed from heredoc:\n%s", string(content))
+	}
+	if !strings.Contains(string(content), "contents = <<EOF\nEOF\n") {
+		t.Fatalf("heredoc markers should be preserved:\n%s", string(content))
+	}
+}
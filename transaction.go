@@ -0,0 +1,106 @@
+package tftidy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// errRollbackFailed marks a commit error after which some files could not be
+// restored to their original content.
+var errRollbackFailed = errors.New("failed to roll back")
+
+// fileChange is the new content computed for a file, kept with its original
+// content so the write can be rolled back. created marks a file that does not
+// exist yet and deleted a file that is removed instead of rewritten.
+type fileChange struct {
+	path     string
+	created  bool
+	deleted  bool
+	original []byte
+	updated  []byte
+	types    []string
+	counts   map[string]int
+}
+
+// commitChanges writes every change or none of them. All temporary files are
+// written and synced first and the backups after them, so a file that cannot
+// be prepared aborts the run before any backup is written. Only a failing
+// rename or removal can interrupt the commit; files changed before it are then
+// restored from their original content.
+func commitChanges(changes []fileChange, backup backupOptions, preserveMtime bool) error {
+	pending := make([]*pendingWrite, 0, len(changes))
+	for _, change := range changes {
+		var (
+			w   *pendingWrite
+			err error
+		)
+		switch {
+		case change.deleted:
+			// Deleted files have nothing to prepare.
+		case change.created:
+			w, err = prepareCreate(change.path, change.updated)
+		default:
+			w, err = prepareWrite(change.path, change.updated, preserveMtime)
+		}
+		if err != nil {
+			discardAll(pending)
+			return fmt.Errorf("failed to prepare %s: %w", change.path, err)
+		}
+		pending = append(pending, w)
+	}
+
+	if backup.enabled() {
+		for _, change := range changes {
+			if change.created {
+				continue
+			}
+			if err := writeBackup(change.path, change.original, backup); err != nil {
+				discardAll(pending)
+				return fmt.Errorf("failed to write backup of %s: %w", change.path, err)
+			}
+		}
+	}
+
+	for i, w := range pending {
+		var err error
+		if changes[i].deleted {
+			if err = os.Remove(changes[i].path); err != nil {
+				err = fmt.Errorf("failed to delete %s: %w", changes[i].path, err)
+			}
+		} else if err = w.commit(); err != nil {
+			err = fmt.Errorf("failed to write %s: %w", w.path, err)
+		}
+		if err == nil {
+			continue
+		}
+
+		discardAll(pending[i+1:])
+		for _, change := range changes[:i] {
+			var rollbackErr error
+			switch {
+			case change.created:
+				rollbackErr = os.Remove(change.path)
+			case change.deleted:
+				rollbackErr = createFileAtomic(change.path, change.original)
+			default:
+				rollbackErr = writeFileAtomic(change.path, change.original, preserveMtime)
+			}
+			if rollbackErr != nil {
+				err = errors.Join(err, fmt.Errorf("%w %s: %w", errRollbackFailed, change.path, rollbackErr))
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// discardAll discards the prepared writes; deleted changes have none.
+func discardAll(pending []*pendingWrite) {
+	for _, w := range pending {
+		if w != nil {
+			w.discard()
+		}
+	}
+}
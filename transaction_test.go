@@ -0,0 +1,155 @@
+package tftidy
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommitChanges(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	first := filepath.Join(tempDir, "first.tf")
+	second := filepath.Join(tempDir, "second.tf")
+	mustWriteFile(t, first, "old first\n", 0o644)
+	mustWriteFile(t, second, "old second\n", 0o644)
+
+	changes := []fileChange{
+		{path: first, original: []byte("old first\n"), updated: []byte("new first\n")},
+		{path: second, original: []byte("old second\n"), updated: []byte("new second\n")},
+	}
+	if err := commitChanges(changes, backupOptions{}, false); err != nil {
+		t.Fatalf("commitChanges failed: %v", err)
+	}
+
+	for path, want := range map[string]string{first: "new first\n", second: "new second\n"} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		if string(content) != want {
+			t.Fatalf("unexpected content in %s: %q", path, string(content))
+		}
+	}
+}
+
+func TestCommitChangesDeleted(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	kept := filepath.Join(tempDir, "main.tf")
+	deleted := filepath.Join(tempDir, "moved.tf")
+	mustWriteFile(t, kept, "old main\n", 0o644)
+	mustWriteFile(t, deleted, "old moved\n", 0o644)
+
+	changes := []fileChange{
+		{path: deleted, deleted: true, original: []byte("old moved\n")},
+		{path: kept, original: []byte("old main\n"), updated: []byte("new main\n")},
+	}
+	if err := commitChanges(changes, backupOptions{suffix: ".bak"}, false); err != nil {
+		t.Fatalf("commitChanges failed: %v", err)
+	}
+
+	if _, err := os.Stat(deleted); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be deleted, got %v", deleted, err)
+	}
+	backup, err := os.ReadFile(deleted + ".bak")
+	if err != nil {
+		t.Fatalf("failed to read backup: %v", err)
+	}
+	if string(backup) != "old moved\n" {
+		t.Fatalf("unexpected backup content: %q", string(backup))
+	}
+	content, err := os.ReadFile(kept)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "new main\n" {
+		t.Fatalf("unexpected content: %q", string(content))
+	}
+}
+
+func TestCommitChangesPrepareFailure(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	first := filepath.Join(tempDir, "first.tf")
+	mustWriteFile(t, first, "old\n", 0o644)
+
+	changes := []fileChange{
+		{path: first, original: []byte("old\n"), updated: []byte("new\n")},
+		{path: filepath.Join(tempDir, "deleted.tf"), original: []byte("old\n"), updated: []byte("new\n")},
+	}
+	err := commitChanges(changes, backupOptions{suffix: ".bak"}, false)
+	if err == nil || !strings.Contains(err.Error(), "deleted.tf") {
+		t.Fatalf("expected prepare error for deleted.tf, got %v", err)
+	}
+
+	content, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "old\n" {
+		t.Fatalf("no file may be written when a prepare fails: %q", string(content))
+	}
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("temporary files or backups left behind: %v", entries)
+	}
+}
+
+func TestIntegrationRunAtomicRun(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	moved := "moved {\n  from = aws_instance.old\n  to   = aws_instance.main\n}\n"
+	valid := filepath.Join(tempDir, "a.tf")
+	mustWriteFile(t, valid, moved, 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, "b.tf"), "moved {\n", 0o644)
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	code := run([]string{"--atomic-run", tempDir}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "Aborted: 1 file(s) failed, no files were modified") {
+		t.Fatalf("unexpected stderr: %s", stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Files modified: 0") {
+		t.Fatalf("unexpected stats:\n%s", stdout.String())
+	}
+	content, err := os.ReadFile(valid)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != moved {
+		t.Fatalf("valid file must not be modified: %q", string(content))
+	}
+
+	if err := os.Remove(filepath.Join(tempDir, "b.tf")); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"--atomic-run", tempDir}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d stderr=%s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Files modified: 1") || !strings.Contains(stdout.String(), "moved:   1") {
+		t.Fatalf("unexpected stats:\n%s", stdout.String())
+	}
+	content, err = os.ReadFile(valid)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "" {
+		t.Fatalf("moved block should be removed: %q", string(content))
+	}
+}
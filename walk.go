@@ -0,0 +1,290 @@
+package tftidy
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	gitignore "github.com/boyter/gocodewalker/go-gitignore"
+)
+
+// alwaysExcludedDirs are directory names that are never walked, even with
+// --hidden: git metadata and downloaded or generated copies of modules.
+var alwaysExcludedDirs = []string{".git", ".terraform", ".terragrunt-cache"}
+
+// ignoreFileName is the tftidy-specific ignore file, read in addition to
+// .gitignore and .ignore.
+const ignoreFileName = ".tftidyignore"
+
+var gitModulePathPattern = regexp.MustCompile(`^\s*path\s*=\s*(.*)`)
+
+// ignoreRules is one ignore file, or one submodule path of a .gitmodules file,
+// that applies to the directory it was found in and everything below it.
+type ignoreRules struct {
+	path    string
+	line    int
+	matcher gitignore.GitIgnore
+}
+
+// walker recursively collects files below root. It honors .gitignore,
+// .ignore, .tftidyignore, and .gitmodules files the same way gocodewalker
+// does, skips hidden entries, and applies the include and exclude globs of
+// opts to paths relative to root. Excluded directories are pruned rather than
+// walked, and a directory matching an include glob includes everything below
+// it. Symlinks are only followed with followSymlinks.
+type walker struct {
+	root  string
+	opts  discoverOptions
+	keep  func(name string) bool
+	files []string
+
+	// backupDir is the backup directory of opts, or nil when there is none
+	// or it does not exist yet.
+	backupDir os.FileInfo
+
+	// walking holds the resolved paths of the directories being walked, from
+	// the root down to the current one.
+	walking []string
+}
+
+func walkFiles(root string, opts discoverOptions, keep func(name string) bool) ([]string, error) {
+	w := &walker{root: root, opts: opts, keep: keep}
+	if opts.backupDir != "" {
+		if info, err := os.Stat(opts.backupDir); err == nil && info.IsDir() {
+			w.backupDir = info
+		}
+	}
+	if err := w.walkDir(root, nil, len(opts.include) == 0); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(w.files)
+	return w.files, nil
+}
+
+func (w *walker) walkDir(dir string, ignores []ignoreRules, included bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	if w.opts.followSymlinks {
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return err
+		}
+		w.walking = append(w.walking, real)
+		defer func() { w.walking = w.walking[:len(w.walking)-1] }()
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !w.readsIgnoreFile(entry.Name()) {
+			continue
+		}
+		ignorePath := filepath.Join(dir, entry.Name())
+		content, err := os.ReadFile(ignorePath)
+		if err != nil {
+			return err
+		}
+
+		if entry.Name() != ".gitmodules" {
+			ignores = append(ignores, ignoreRules{path: ignorePath, matcher: gitignore.New(bytes.NewReader(content), absDir, nil)})
+			continue
+		}
+		for i, line := range strings.Split(string(content), "\n") {
+			if m := gitModulePathPattern.FindStringSubmatch(line); m != nil {
+				matcher := gitignore.New(strings.NewReader(strings.TrimSpace(m[1])), absDir, nil)
+				ignores = append(ignores, ignoreRules{path: ignorePath, line: i + 1, matcher: matcher})
+			}
+		}
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		joined := filepath.Join(dir, name)
+		isDir := entry.IsDir()
+		isLink := entry.Type()&fs.ModeSymlink != 0
+		var target os.FileInfo
+		if isLink {
+			if info, err := os.Stat(joined); err == nil {
+				target = info
+				isDir = info.IsDir()
+			}
+		}
+		if !isDir && !w.keep(name) {
+			continue
+		}
+
+		rel := w.relative(joined)
+		reason := w.skipReason(name, rel, filepath.Join(absDir, name), isDir, ignores)
+		if reason == "" && isLink {
+			reason = w.symlinkReason(joined, target, isDir)
+		}
+		if reason != "" {
+			if w.opts.skipped != nil {
+				w.opts.skipped(joined, reason)
+			}
+			continue
+		}
+
+		matched := included || matchAnyGlob(w.opts.include, rel)
+		if isDir {
+			if err := w.walkDir(joined, ignores, matched); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if matched {
+			w.files = append(w.files, filepath.Clean(joined))
+		}
+	}
+
+	return nil
+}
+
+// readsIgnoreFile reports whether the walk honors ignore files named name.
+func (w *walker) readsIgnoreFile(name string) bool {
+	switch name {
+	case ".gitignore", ".gitmodules":
+		return !w.opts.noIgnore && !w.opts.noGitignore
+	case ".ignore", ignoreFileName:
+		return !w.opts.noIgnore
+	default:
+		return false
+	}
+}
+
+// skipReason explains why the entry at rel is left out of the walk, or returns
+// "" when it is walked.
+func (w *walker) skipReason(name, rel, absPath string, isDir bool, ignores []ignoreRules) string {
+	if isDir && isAlwaysExcludedDir(name) {
+		return "always excluded"
+	}
+	if isDir && w.backupDir != nil {
+		if info, err := os.Stat(absPath); err == nil && os.SameFile(info, w.backupDir) {
+			return "backup directory"
+		}
+	}
+	if !w.opts.hidden && strings.HasPrefix(name, ".") {
+		return "hidden; use --hidden to include"
+	}
+	if rules, m := matchIgnoreRules(ignores, absPath, isDir); m != nil && m.Ignore() {
+		line := rules.line
+		if line == 0 {
+			line = m.Position().Line
+		}
+		return fmt.Sprintf("ignored by %s:%d: %s", rules.path, line, m.String())
+	}
+	for _, pattern := range w.opts.exclude {
+		if matchGlob(pattern, rel) {
+			return fmt.Sprintf("excluded by %q", pattern)
+		}
+	}
+	return ""
+}
+
+// symlinkReason explains why the symlink at path is not followed, or returns
+// "" when it is. target is the file it points to, or nil when it is broken.
+func (w *walker) symlinkReason(path string, target os.FileInfo, isDir bool) string {
+	if !w.opts.followSymlinks {
+		return "symlink; use --follow-symlinks to follow"
+	}
+	if target == nil {
+		return "broken symlink"
+	}
+	if !isDir {
+		return ""
+	}
+
+	// A link to the directory being walked or one of its parents would
+	// never end.
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "broken symlink"
+	}
+	for _, ancestor := range w.walking {
+		if real == ancestor {
+			return "symlink cycle"
+		}
+	}
+	return ""
+}
+
+// relative returns p relative to the walk root, with forward slashes.
+func (w *walker) relative(p string) string {
+	rel, err := filepath.Rel(w.root, p)
+	if err != nil {
+		return filepath.ToSlash(p)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func isAlwaysExcludedDir(name string) bool {
+	for _, excluded := range alwaysExcludedDirs {
+		if name == excluded {
+			return true
+		}
+	}
+	return false
+}
+
+// matchIgnoreRules applies ignore files from the outermost to the innermost
+// directory; the last one with a matching pattern decides.
+func matchIgnoreRules(ignores []ignoreRules, absPath string, isDir bool) (ignoreRules, gitignore.Match) {
+	var (
+		rules ignoreRules
+		match gitignore.Match
+	)
+	for _, ig := range ignores {
+		if m := ig.matcher.Absolute(absPath, isDir); m != nil {
+			rules, match = ig, m
+		}
+	}
+	return rules, match
+}
+
+func matchAnyGlob(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated relative path against a doublestar
+// glob, after dropping leading "./" and leading or trailing slashes. Patterns
+// are checked by validateGlobs before walking; an invalid one matches nothing.
+func matchGlob(pattern, rel string) bool {
+	ok, err := doublestar.Match(normalizeGlob(pattern), rel)
+	return err == nil && ok
+}
+
+func normalizeGlob(pattern string) string {
+	pattern = strings.Trim(filepath.ToSlash(pattern), "/")
+	return strings.TrimPrefix(pattern, "./")
+}
+
+// validateGlobs reports the first pattern doublestar cannot parse, so that a
+// typo fails the run instead of silently matching nothing. kind names the
+// patterns in the error, e.g. "exclude".
+func validateGlobs(kind string, patterns []string) error {
+	for _, pattern := range patterns {
+		if !doublestar.ValidatePattern(normalizeGlob(pattern)) {
+			return fmt.Errorf("invalid %s glob %q", kind, pattern)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package tftidy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{pattern: "*.tf", path: "main.tf", want: true},
+		{pattern: "*.tf", path: "nested/main.tf", want: false},
+		{pattern: "**/*.tf", path: "main.tf", want: true},
+		{pattern: "**/*.tf", path: "a/b/main.tf", want: true},
+		{pattern: "modules/**", path: "modules/vpc/main.tf", want: true},
+		{pattern: "modules/**", path: "modules", want: true},
+		{pattern: "**/examples", path: "modules/vpc/examples", want: true},
+		{pattern: "**/examples/**/*.tf", path: "examples/main.tf", want: true},
+		{pattern: "./live/*", path: "live/prod", want: true},
+		{pattern: "live/", path: "live", want: true},
+		{pattern: "live/[", path: "live/[", want: false},
+		{pattern: "env/prod", path: "env/production", want: false},
+		{pattern: "{examples,fixtures}", path: "fixtures", want: true},
+		{pattern: "**/{examples,fixtures}", path: "modules/vpc/examples", want: true},
+		{pattern: "{examples,fixtures}", path: "tests", want: false},
+		{pattern: "live/{prod,stage}/*.tf", path: "live/stage/main.tf", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestValidateGlobs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		patterns []string
+		wantErr  bool
+	}{
+		{patterns: []string{"**/examples", "{examples,fixtures}", "live/[a-z]*"}},
+		{patterns: []string{"modules/**", "live/["}, wantErr: true},
+		{patterns: []string{"{examples,fixtures"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := validateGlobs("exclude", tt.patterns); (err != nil) != tt.wantErr {
+			t.Errorf("validateGlobs(%q) error = %v, wantErr %v", tt.patterns, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWalkFilesIncludeExclude(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	for _, name := range []string{
+		"main.tf",
+		filepath.Join("modules", "vpc", "main.tf"),
+		filepath.Join("modules", "vpc", "examples", "basic", "main.tf"),
+		filepath.Join("live", "prod", "main.tf"),
+		filepath.Join("live", "dev", "main.tf"),
+	} {
+		mustMkdirAll(t, filepath.Join(tempDir, filepath.Dir(name)))
+		mustWriteFile(t, filepath.Join(tempDir, name), "", 0o644)
+	}
+
+	opts := discoverOptions{
+		include: []string{"modules", "live/*/main.tf"},
+		exclude: []string{"**/examples", "live/dev"},
+	}
+	files, err := walkFiles(tempDir, opts, isTerraformFile)
+	if err != nil {
+		t.Fatalf("walkFiles failed: %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(tempDir, "live", "prod", "main.tf"),
+		filepath.Join(tempDir, "modules", "vpc", "main.tf"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("unexpected files\nexpected: %#v\nactual: %#v", expected, files)
+	}
+}
+
+func TestWalkFilesPrunesExcludedDirs(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	mustWriteFile(t, filepath.Join(tempDir, "main.tf"), "", 0o644)
+	mustMkdirAll(t, filepath.Join(tempDir, "vendor"))
+	// Walking into vendor would fail on the dangling ignore file.
+	if err := os.Symlink(filepath.Join(tempDir, "missing"), filepath.Join(tempDir, "vendor", ".gitignore")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	if _, err := walkFiles(tempDir, discoverOptions{}, isTerraformFile); err == nil {
+		t.Fatal("expected an error when walking vendor")
+	}
+
+	files, err := walkFiles(tempDir, discoverOptions{exclude: []string{"vendor"}}, isTerraformFile)
+	if err != nil {
+		t.Fatalf("excluded directory must not be walked: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{filepath.Join(tempDir, "main.tf")}) {
+		t.Fatalf("unexpected files: %#v", files)
+	}
+}
+
+func TestWalkFilesIgnoreOptions(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	mustWriteFile(t, filepath.Join(tempDir, ".gitignore"), "git.tf\n", 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, ".ignore"), "ignore.tf\n", 0o644)
+	mustWriteFile(t, filepath.Join(tempDir, ".tftidyignore"), "generated/\n", 0o644)
+	mustMkdirAll(t, filepath.Join(tempDir, "generated"))
+	mustMkdirAll(t, filepath.Join(tempDir, ".hidden"))
+	mustMkdirAll(t, filepath.Join(tempDir, ".git"))
+	for _, name := range []string{"main.tf", "git.tf", "ignore.tf", filepath.Join("generated", "gen.tf"), ".dot.tf", filepath.Join(".hidden", "main.tf"), filepath.Join(".git", "main.tf")} {
+		mustWriteFile(t, filepath.Join(tempDir, name), "", 0o644)
+	}
+
+	tests := []struct {
+		name     string
+		opts     discoverOptions
+		expected []string
+	}{
+		{
+			name:     "default",
+			expected: []string{"main.tf"},
+		},
+		{
+			name:     "no gitignore",
+			opts:     discoverOptions{noGitignore: true},
+			expected: []string{"git.tf", "main.tf"},
+		},
+		{
+			name:     "no ignore",
+			opts:     discoverOptions{noIgnore: true},
+			expected: []string{"generated/gen.tf", "git.tf", "ignore.tf", "main.tf"},
+		},
+		{
+			name:     "hidden",
+			opts:     discoverOptions{hidden: true},
+			expected: []string{".dot.tf", ".hidden/main.tf", "main.tf"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			files, err := walkFiles(tempDir, tt.opts, isTerraformFile)
+			if err != nil {
+				t.Fatalf("walkFiles failed: %v", err)
+			}
+
+			expected := make([]string, 0, len(tt.expected))
+			for _, name := range tt.expected {
+				expected = append(expected, filepath.Join(tempDir, filepath.FromSlash(name)))
+			}
+			if !reflect.DeepEqual(files, expected) {
+				t.Fatalf("unexpected files\nexpected: %#v\nactual: %#v", expected, files)
+			}
+		})
+	}
+}
+
+func TestWalkFilesReportsSkipReasons(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	mustWriteFile(t, filepath.Join(tempDir, ".tftidyignore"), "# generated\nzz_*.tf\n", 0o644)
+	mustMkdirAll(t, filepath.Join(tempDir, "vendor"))
+	mustMkdirAll(t, filepath.Join(tempDir, ".terraform"))
+	for _, name := range []string{"main.tf", "zz_gen.tf", ".dot.tf", "notes.txt"} {
+		mustWriteFile(t, filepath.Join(tempDir, name), "", 0o644)
+	}
+
+	skipped := make(map[string]string)
+	opts := discoverOptions{
+		exclude: []string{"vendor"},
+		skipped: func(path, reason string) { skipped[filepath.Base(path)] = reason },
+	}
+	if _, err := walkFiles(tempDir, opts, isTerraformFile); err != nil {
+		t.Fatalf("walkFiles failed: %v", err)
+	}
+
+	expected := map[string]string{
+		".terraform": "always excluded",
+		".dot.tf":    "hidden; use --hidden to include",
+		"zz_gen.tf":  "ignored by " + filepath.Join(tempDir, ".tftidyignore") + ":2: zz_*.tf",
+		"vendor":     "excluded by \"vendor\"",
+	}
+	if !reflect.DeepEqual(skipped, expected) {
+		t.Fatalf("unexpected skip reasons\nexpected: %#v\nactual: %#v", expected, skipped)
+	}
+}
@@ -0,0 +1,140 @@
+package tftidy
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// newFileMode is the mode of files tftidy creates.
+const newFileMode fs.FileMode = 0o644
+
+// preservedModeBits are the mode bits copied from the original file to its
+// replacement.
+const preservedModeBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// writeFileAtomic replaces the contents of path without ever leaving it
+// truncated: data is written to a temporary file in the same directory,
+// synced, and renamed over the original. A symlink is written through, not
+// replaced. The original mode and, where permitted, owner and group are kept;
+// with preserveMtime the modification time is kept as well.
+func writeFileAtomic(path string, data []byte, preserveMtime bool) error {
+	w, err := prepareWrite(path, data, preserveMtime)
+	if err != nil {
+		return err
+	}
+	return w.commit()
+}
+
+// pendingWrite is a fully written and synced temporary file waiting to be
+// renamed over its target.
+type pendingWrite struct {
+	path   string
+	target string
+	tmp    string
+}
+
+// prepareWrite does everything writeFileAtomic does except the final rename.
+func prepareWrite(path string, data []byte, preserveMtime bool) (_ *pendingWrite, err error) {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".tftidy-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return nil, err
+	}
+	// Changing the owner may clear setuid and setgid bits, so it goes first.
+	preserveOwner(tmp, info)
+	if err = tmp.Chmod(info.Mode() & preservedModeBits); err != nil {
+		return nil, err
+	}
+	if err = tmp.Sync(); err != nil {
+		return nil, err
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, err
+	}
+	if preserveMtime {
+		if err = os.Chtimes(tmp.Name(), time.Time{}, info.ModTime()); err != nil {
+			return nil, err
+		}
+	}
+
+	return &pendingWrite{path: path, target: target, tmp: tmp.Name()}, nil
+}
+
+// createFileAtomic writes a new file the same way writeFileAtomic replaces
+// one, with default permissions.
+func createFileAtomic(path string, data []byte) error {
+	w, err := prepareCreate(path, data)
+	if err != nil {
+		return err
+	}
+	return w.commit()
+}
+
+// prepareCreate does everything createFileAtomic does except the final
+// rename.
+func prepareCreate(path string, data []byte) (_ *pendingWrite, err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tftidy-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return nil, err
+	}
+	if err = tmp.Chmod(newFileMode); err != nil {
+		return nil, err
+	}
+	if err = tmp.Sync(); err != nil {
+		return nil, err
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	return &pendingWrite{path: path, target: path, tmp: tmp.Name()}, nil
+}
+
+// commit renames the temporary file over the target. The temporary file is
+// removed if the rename fails. Once the rename succeeded the target holds the
+// new, already synced content, so a failure to sync the directory afterwards
+// only weakens durability across a crash; reporting it as a failed write
+// would make callers roll back or retry a file that was in fact replaced.
+func (w *pendingWrite) commit() error {
+	if err := os.Rename(w.tmp, w.target); err != nil {
+		w.discard()
+		return err
+	}
+	_ = syncDir(filepath.Dir(w.target))
+	return nil
+}
+
+// discard removes the temporary file without touching the target.
+func (w *pendingWrite) discard() {
+	_ = os.Remove(w.tmp)
+}
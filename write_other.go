@@ -0,0 +1,13 @@
+//go:build !unix
+
+package tftidy
+
+import "os"
+
+// preserveOwner is a no-op where files have no Unix owner and group.
+func preserveOwner(*os.File, os.FileInfo) {}
+
+// syncDir is a no-op where directories cannot be synced.
+func syncDir(string) error {
+	return nil
+}
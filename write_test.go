@@ -0,0 +1,86 @@
+package tftidy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "main.tf")
+	mustWriteFile(t, file, "old\n", 0o640)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatalf("failed to set mtime: %v", err)
+	}
+
+	if err := writeFileAtomic(file, []byte("new\n"), true); err != nil {
+		t.Fatalf("writeFileAtomic failed: %v", err)
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "new\n" {
+		t.Fatalf("unexpected content: %q", string(content))
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("failed to stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Fatalf("mode not preserved: %v", info.Mode())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Fatalf("mtime not preserved: %v", info.ModTime())
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("temporary file left behind: %v", entries)
+	}
+
+	if err := writeFileAtomic(file, []byte("newer\n"), false); err != nil {
+		t.Fatalf("writeFileAtomic failed: %v", err)
+	}
+	if info, err = os.Stat(file); err != nil || info.ModTime().Equal(mtime) {
+		t.Fatalf("mtime should be updated without preserveMtime: %v %v", info.ModTime(), err)
+	}
+}
+
+func TestWriteFileAtomicThroughSymlink(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "real.tf")
+	link := filepath.Join(tempDir, "link.tf")
+	mustWriteFile(t, file, "old\n", 0o644)
+	mustSymlink(t, file, link)
+
+	if err := writeFileAtomic(link, []byte("new\n"), false); err != nil {
+		t.Fatalf("writeFileAtomic failed: %v", err)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatalf("failed to stat link: %v", err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("symlink was replaced by a regular file")
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "new\n" {
+		t.Fatalf("target not updated: %q", string(content))
+	}
+}
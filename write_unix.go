@@ -0,0 +1,31 @@
+//go:build unix
+
+package tftidy
+
+import (
+	"os"
+	"syscall"
+)
+
+// preserveOwner gives f the owner and group of info. Only privileged users
+// may change the owner, so the group alone is tried next; failures are
+// ignored and leave the file owned by the current user.
+func preserveOwner(f *os.File, info os.FileInfo) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	if f.Chown(int(st.Uid), int(st.Gid)) != nil {
+		_ = f.Chown(-1, int(st.Gid))
+	}
+}
+
+// syncDir flushes the directory entry of a renamed file to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+	return d.Sync()
+}
@@ -0,0 +1,40 @@
+//go:build unix
+
+package tftidy
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestWriteFileAtomicPreservesOwner(t *testing.T) {
+	t.Parallel()
+
+	if os.Geteuid() != 0 {
+		t.Skip("changing file ownership requires root")
+	}
+
+	file := filepath.Join(t.TempDir(), "main.tf")
+	mustWriteFile(t, file, "old\n", 0o644)
+	if err := os.Chown(file, 12345, 23456); err != nil {
+		t.Fatalf("failed to chown: %v", err)
+	}
+
+	if err := writeFileAtomic(file, []byte("new\n"), false); err != nil {
+		t.Fatalf("writeFileAtomic failed: %v", err)
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("failed to stat file: %v", err)
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		t.Skip("no Unix ownership information")
+	}
+	if st.Uid != 12345 || st.Gid != 23456 {
+		t.Fatalf("owner not preserved: %d:%d", st.Uid, st.Gid)
+	}
+}